@@ -2,6 +2,11 @@ package flagly
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 type CmdHelp struct{}
@@ -9,6 +14,7 @@ type CmdHelp struct{}
 func NewHandlerHelp() *Handler {
 	h := NewHandler("help")
 	h.CompileIface(&CmdHelp{})
+	h.ignoreRequired = true
 	return h
 }
 
@@ -16,6 +22,120 @@ func (CmdHelp) FlaglyHandle(h *Handler) error {
 	return errors.New(h.GetRoot().Usage(""))
 }
 
+func NewHandlerCompletion() *Handler {
+	h := NewHandler("completion")
+	h.CompileIface(&CmdCompletion{})
+	h.ignoreRequired = true
+	return h
+}
+
+func NewHandlerComplete() *Handler {
+	h := NewHandler("__complete")
+	h.CompileIface(&CmdComplete{})
+	h.Hidden = true
+	h.ignoreRequired = true
+	return h
+}
+
 func (CmdHelp) FlaglyDesc() string {
 	return "show help"
 }
+
+// CmdCompletion prints the completion script of a shell, which calls back
+// to the hidden `__complete` command, see FlaglySet.EnableCompletion.
+type CmdCompletion struct {
+	Shell string `type:"[0]" select:"bash,zsh,fish" required:"true"`
+}
+
+func (c *CmdCompletion) FlaglyHandle(h *Handler) error {
+	script, err := CompletionScript(c.Shell, h.GetRoot().Name)
+	if err != nil {
+		return Error(err.Error())
+	}
+	_, err = io.WriteString(h.setting.output, script)
+	return err
+}
+
+func (CmdCompletion) FlaglyDesc() string {
+	return "generate the shell completion script"
+}
+
+// CmdComplete prints the candidates of the command line, one per line,
+// the description follows the value after a tab if any.
+type CmdComplete struct {
+	Words []string `type:"[]"`
+}
+
+func (c *CmdComplete) FlaglyHandle(h *Handler) error {
+	hc := &HandlerCompleter{h.GetRoot()}
+	for _, cand := range hc.CompleteCandidates(c.Words) {
+		line := cand.Value
+		if cand.Desc != "" {
+			line += "\t" + cand.Desc
+		}
+		if _, err := fmt.Fprintln(h.setting.output, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompletionScript returns the completion script of the program for bash,
+// zsh or fish, the name of the running program is used if it's empty.
+func CompletionScript(shell, program string) (string, error) {
+	tmpl, ok := completionScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell: %v", shell)
+	}
+	if program == "" && len(os.Args) > 0 {
+		program = os.Args[0]
+	}
+	if program == "" {
+		return "", errors.New("the program name is required")
+	}
+	program = filepath.Base(program)
+	fn := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, program)
+	r := strings.NewReplacer("{{program}}", program, "{{func}}", fn)
+	return r.Replace(tmpl), nil
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{program}}
+_{{func}}_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($({{program}} __complete -- "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null | cut -f1))
+}
+complete -o default -F _{{func}}_complete {{program}}
+`,
+	"zsh": `#compdef {{program}}
+_{{func}}_complete() {
+    local -a lines candidates
+    local line value
+    lines=("${(@f)$({{program}} __complete -- "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    for line in "${lines[@]}"; do
+        [[ -z "$line" ]] && continue
+        value="${line%%$'\t'*}"
+        value="${value//:/\\:}"
+        if [[ "$line" == *$'\t'* ]]; then
+            candidates+=("$value:${line#*$'\t'}")
+        else
+            candidates+=("$value")
+        fi
+    done
+    _describe -t values '{{program}}' candidates
+}
+compdef _{{func}}_complete {{program}}
+`,
+	"fish": `# fish completion for {{program}}
+function __{{func}}_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    {{program}} __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c {{program}} -f -a '(__{{func}}_complete)'
+`,
+}
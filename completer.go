@@ -1,39 +1,398 @@
 package flagly
 
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
 type Tree interface {
 	GetName() string
 	GetTreeChildren() []Tree
 }
 
+// Candidate is a completion candidate, Desc is shown by the shells which
+// support it, e.g. zsh and fish.
+type Candidate struct {
+	Value string
+	Desc  string
+}
+
+// Completer provides the candidates of the values dynamically, it can be
+// implemented by a Typer or the type of an option. prefix is the word
+// being typed, partial is the option struct of h filled with the options
+// typed so far, it's invalid if h has no option struct.
+type Completer interface {
+	Complete(prefix string, h *Handler, partial reflect.Value) []Candidate
+}
+
+var emptyCompleter = reflect.TypeOf(new(Completer)).Elem()
+
+// LambdaCompleter adapts the function registered by Lambda to a Completer
+func LambdaCompleter(fn func() []string) Completer {
+	return lambdaCompleter(fn)
+}
+
+type lambdaCompleter func() []string
+
+func (fn lambdaCompleter) Complete(string, *Handler, reflect.Value) []Candidate {
+	names := fn()
+	ret := make([]Candidate, len(names))
+	for idx, name := range names {
+		ret[idx] = Candidate{Value: name}
+	}
+	return ret
+}
+
 type HandlerCompleter struct {
 	h Tree
 }
 
 func (hc *HandlerCompleter) DoSegment(seg [][]rune, n int) [][]rune {
-	h := hc.h
+	words := make([]string, len(seg))
+	for idx, s := range seg {
+		words[idx] = string(s)
+	}
+	cands := hc.candidates(words)
+	ret := make([][]rune, len(cands))
+	for idx, cand := range cands {
+		ret[idx] = []rune(cand.Value)
+	}
+	return ret
+}
+
+// Complete returns the candidates of the last word, words is the command
+// line without the program name and the last one is the word being typed,
+// which is empty if it's not started yet.
+func (hc *HandlerCompleter) Complete(words []string) []string {
+	var ret []string
+	for _, cand := range hc.CompleteCandidates(words) {
+		ret = append(ret, cand.Value)
+	}
+	return ret
+}
+
+// CompleteCandidates is like Complete but the candidates come with their
+// descriptions.
+func (hc *HandlerCompleter) CompleteCandidates(words []string) []Candidate {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prefix := words[len(words)-1]
+	var ret []Candidate
+	for _, cand := range hc.candidates(words) {
+		if strings.HasPrefix(cand.Value, prefix) {
+			ret = append(ret, cand)
+		}
+	}
+	return ret
+}
+
+// completeState is what the words before the last one are parsed into
+type completeState struct {
+	h Tree
+	// the flag which the last word is the value of
+	valueOf  *Option
+	flagsEnd bool
+
+	// the options given to h, see partial
+	flags      []*Option
+	flagValues [][]string
+	positional []string
+}
+
+func (s *completeState) enter(child Tree) {
+	*s = completeState{h: child}
+}
+
+func (s *completeState) addFlag(op *Option, vals []string) {
+	if h, ok := s.h.(*Handler); ok && op.handler != nil && op.handler != h {
+		// the persistent flag of an ancestor
+		return
+	}
+	s.flags = append(s.flags, op)
+	s.flagValues = append(s.flagValues, vals)
+}
+
+// partial binds the options typed so far to the option struct of h, the
+// invalid values are ignored.
+func (s *completeState) partial(h *Handler) reflect.Value {
+	if h.OptionType == nil {
+		return reflect.Value{}
+	}
+	t := h.OptionType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	value := reflect.New(t)
+	for idx, op := range s.flags {
+		op.BindTo(value, s.flagValues[idx])
+	}
+	for _, op := range h.Options {
+		if !op.IsArg() {
+			continue
+		}
+		if op.ArgIdx == -1 && len(s.positional) > 0 {
+			op.BindTo(value, s.positional)
+		} else if op.ArgIdx >= 0 && op.ArgIdx < len(s.positional) {
+			op.BindTo(value, s.positional[op.ArgIdx:op.ArgIdx+1])
+		}
+	}
+	return value
+}
+
+// walk parses the words before the last one, returns nil if they don't
+// match the tree.
+func (hc *HandlerCompleter) walk(words []string) *completeState {
+	s := &completeState{h: hc.h}
 main:
-	for level := 0; level < len(seg)-1; {
-		name := string(seg[level])
-		children := h.GetTreeChildren()
-		for _, child := range children {
+	for level := 0; level < len(words)-1; {
+		name := words[level]
+		if handler, ok := s.h.(*Handler); ok && !s.flagsEnd && isFlagSegment(name) {
+			level++
+			if name == "--" {
+				s.flagsEnd = true
+				continue
+			}
+			op := handler.completeFlag(name)
+			if op == nil {
+				continue
+			}
+			if _, value, ok := strings.Cut(name, "="); ok {
+				s.addFlag(op, []string{value})
+				continue
+			}
+			min, max := op.Typer.NumArgs()
+			if max == 0 {
+				s.addFlag(op, []string{})
+				continue
+			}
+			if level == len(words)-1 {
+				if min > 0 {
+					s.valueOf = op
+				}
+				break
+			}
+			if min > 0 || op.Typer.CanBeValue(words[level]) {
+				// skip the value of the flag
+				s.addFlag(op, []string{words[level]})
+				level++
+			} else {
+				s.addFlag(op, []string{})
+			}
+			continue
+		}
+		if handler, ok := s.h.(*Handler); ok && len(handler.GetChildren()) == 0 {
+			// the arguments of a leaf handler
+			s.positional = append(s.positional, name)
+			level++
+			continue
+		}
+		if handler, ok := s.h.(*Handler); ok {
+			if child, _ := handler.findChild(name); child != nil {
+				s.enter(child)
+				level++
+				continue
+			}
+		}
+		for _, child := range s.h.GetTreeChildren() {
 			if child.GetName() == name {
-				h = child
+				s.enter(child)
 				level++
 				continue main
 			}
 		}
-		h = nil
-		break
+		return nil
+	}
+	return s
+}
+
+// candidates returns the candidates of the last word without filtering
+func (hc *HandlerCompleter) candidates(words []string) []Candidate {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	s := hc.walk(words)
+	if s == nil {
+		return nil
+	}
+
+	handler, ok := s.h.(*Handler)
+	if !ok {
+		return treeCandidates(s.h.GetTreeChildren())
+	}
+	last := words[len(words)-1]
+	if s.valueOf != nil {
+		return handler.valueCandidates(s.valueOf, last, s.partial(handler))
+	}
+	if !s.flagsEnd && strings.HasPrefix(last, "-") {
+		if idx := strings.Index(last, "="); idx > 0 {
+			op := handler.completeFlag(last[:idx])
+			if op == nil {
+				return nil
+			}
+			ret := handler.valueCandidates(op, last[idx+1:], s.partial(handler))
+			for i := range ret {
+				ret[i].Value = last[:idx+1] + ret[i].Value
+			}
+			return ret
+		}
+		return handler.flagCandidates()
+	}
+	if len(handler.GetChildren()) == 0 {
+		if op := handler.findArgOptionAt(len(s.positional)); op != nil {
+			return handler.valueCandidates(op, last, s.partial(handler))
+		}
+		return nil
+	}
+	return treeCandidates(handler.GetTreeChildren())
+}
+
+func isFlagSegment(s string) bool {
+	return strings.HasPrefix(s, "-") && s != "-"
+}
+
+// treeCandidates returns the names of trees, including the aliases of the
+// handlers.
+func treeCandidates(trees []Tree) []Candidate {
+	ret := make([]Candidate, 0, len(trees))
+	for _, t := range trees {
+		h, ok := t.(*Handler)
+		if !ok {
+			ret = append(ret, Candidate{Value: t.GetName()})
+			continue
+		}
+		for _, name := range h.names() {
+			ret = append(ret, Candidate{Value: name, Desc: h.Desc})
+		}
+	}
+	return ret
+}
+
+// completeFlag finds the flag of a segment like `-name` or `--name=value`
+func (h *Handler) completeFlag(seg string) *Option {
+	name, _, _ := strings.Cut(trimFlagName(seg), "=")
+	idx := h.findOption(name)
+	if idx < 0 {
+		return h.findPersistent(name)
+	}
+	if !h.Options[idx].IsFlag() {
+		return nil
+	}
+	return h.Options[idx]
+}
+
+// valueCandidates returns the candidates of the value of op from
+//  1. the `select` or `selectCall` tag
+//  2. the Completer implemented by the Typer or the type of op
+//  3. the FlaglyCompleter implemented by the option struct
+//  4. the local files if op has `complete:"file"` or `complete:"dir"`
+func (h *Handler) valueCandidates(op *Option, prefix string, partial reflect.Value) []Candidate {
+	var ret []Candidate
+	if tags := op.Tag.Get("select"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			ret = append(ret, Candidate{Value: tag})
+		}
+	} else if selectCall := op.Tag.Get("selectCall"); selectCall != "" {
+		if fn := h.lambdaMap[selectCall]; fn != nil {
+			ret = LambdaCompleter(fn).Complete(prefix, h, partial)
+		}
+	}
+
+	if c, ok := op.Typer.(Completer); ok {
+		ret = append(ret, c.Complete(prefix, h, partial)...)
+	} else if c := op.typeCompleter(partial); c != nil {
+		ret = append(ret, c.Complete(prefix, h, partial)...)
+	}
+	if partial.IsValid() && IsImplementCompleter(partial.Type()) {
+		ret = append(ret, partial.Interface().(FlaglyCompleter).FlaglyComplete(op, prefix)...)
+	}
+
+	switch op.Tag.Get("complete") {
+	case "file":
+		ret = append(ret, completeFiles(prefix, false)...)
+	case "dir":
+		ret = append(ret, completeFiles(prefix, true)...)
+	}
+	return ret
+}
+
+// typeCompleter returns the Completer implemented by the type of the
+// option, the field in partial is used if possible.
+func (o *Option) typeCompleter(partial reflect.Value) Completer {
+	t := o.BindType
+	if t == nil || !IsImplemented(t, emptyCompleter) {
+		return nil
+	}
+	var field reflect.Value
+	if partial.IsValid() && o.Index >= 0 {
+		field = partial.Elem().Field(o.Index)
+	} else {
+		field = reflect.New(t).Elem()
+	}
+	if field.Kind() == reflect.Ptr && field.IsNil() {
+		field = reflect.New(t.Elem())
+	}
+	if c, ok := field.Interface().(Completer); ok {
+		return c
+	}
+	if field.CanAddr() {
+		if c, ok := field.Addr().Interface().(Completer); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// completeFiles lists the files in the directory of prefix, the
+// directories end with a slash.
+func completeFiles(prefix string, dirOnly bool) []Candidate {
+	dir, base := filepath.Split(prefix)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
 	}
-	if h == nil {
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
 		return nil
 	}
-	children := h.GetTreeChildren()
-	ret := make([][]rune, len(children))
-	for idx, child := range children {
-		ret[idx] = []rune(child.GetName())
+	var ret []Candidate
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) ||
+			strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(readDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			ret = append(ret, Candidate{Value: dir + name + "/"})
+		} else if !dirOnly {
+			ret = append(ret, Candidate{Value: dir + name})
+		}
 	}
+	return ret
+}
 
+// flagCandidates returns the flags as they are typed, e.g. `-t`, `--template`
+func (h *Handler) flagCandidates() []Candidate {
+	var ret []Candidate
+	for _, op := range append(append([]*Option{}, h.Options...), h.persistentOptions()...) {
+		if !op.IsFlag() || op.Hidden {
+			continue
+		}
+		if !op.HasLongName() || op.Name != op.LongName {
+			ret = append(ret, Candidate{Value: "-" + op.Name, Desc: op.Desc})
+		}
+		if op.HasLongName() {
+			ret = append(ret, Candidate{Value: "--" + op.LongName, Desc: op.Desc})
+		}
+	}
 	return ret
 }
 
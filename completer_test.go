@@ -0,0 +1,257 @@
+package flagly
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type completeRoot struct {
+	Clone  *completeClone  `flagly:"handler"`
+	Config *completeConfig `flagly:"handler"`
+}
+
+type completeClone struct {
+	Repo string `type:"[0]" select:"github,gitlab"`
+}
+
+func (completeClone) FlaglyHandle() error { return nil }
+
+type completeConfig struct {
+	Name string `type:"[0]" selectCall:"configNames"`
+}
+
+func (completeConfig) FlaglyHandle() error { return nil }
+
+func TestCompletion(t *testing.T) {
+	fset, err := Compile("/usr/bin/app", &completeRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.Lambda("configNames", func() []string {
+		return []string{"user.name", "user.email"}
+	})
+	fset.EnableCompletion()
+	hc := fset.Completer()
+
+	for _, c := range []struct {
+		words []string
+		want  []string
+	}{
+		{nil, []string{"clone", "config", "completion"}},
+		{[]string{"c"}, []string{"clone", "config", "completion"}},
+		{[]string{"cl"}, []string{"clone"}},
+		{[]string{"clone", ""}, []string{"github", "gitlab"}},
+		{[]string{"config", "user.e"}, []string{"user.email"}},
+		{[]string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+		{[]string{"unknown", ""}, nil},
+	} {
+		if got := hc.Complete(c.words); !reflect.DeepEqual(got, c.want) {
+			t.Fatal("error", c.words, got)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fset.SetOutput(buf)
+	if err := fset.Run([]string{"__complete", "--", "clone", "git"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "github\ngitlab\n" {
+		t.Fatal("error", buf.String())
+	}
+	if strings.Contains(fset.Usage(), "__complete") {
+		t.Fatal("error", fset.Usage())
+	}
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		buf.Reset()
+		if err := fset.Run([]string{"completion", shell}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "app __complete -- ") {
+			t.Fatal("error", shell, buf.String())
+		}
+	}
+}
+
+type completeRequiredRoot struct {
+	Token string         `name:"token" required:"true"`
+	Clone *completeClone `flagly:"handler"`
+}
+
+func (completeRequiredRoot) FlaglyVerify() error {
+	return Error("verified")
+}
+
+func TestCompletionNoName(t *testing.T) {
+	fset, err := Compile("", &completeRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.EnableCompletion()
+	buf := bytes.NewBuffer(nil)
+	fset.SetOutput(buf)
+	if err := fset.Run([]string{"completion", "bash"}); err != nil {
+		t.Fatal(err)
+	}
+	program := filepath.Base(os.Args[0])
+	if !strings.Contains(buf.String(), "$("+program+" __complete -- ") ||
+		!strings.HasSuffix(buf.String(), "_complete "+program+"\n") {
+		t.Fatal("error", buf.String())
+	}
+}
+
+func TestCompletionRequired(t *testing.T) {
+	fset, err := Compile("app", &completeRequiredRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.EnableCompletion()
+	buf := bytes.NewBuffer(nil)
+	fset.SetOutput(buf)
+	if err := fset.Run([]string{"__complete", "--", "clone", "git"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "github\ngitlab\n" {
+		t.Fatal("error", buf.String())
+	}
+	err = fset.Run([]string{"clone", "github"})
+	var missing *MissingOptionsError
+	if !errors.As(err, &missing) || strings.Join(missing.Names, " ") != "-token" {
+		t.Fatal("error", err)
+	}
+
+	// the help and the builtin commands don't need the root options
+	buf.Reset()
+	if err := fset.Run([]string{"completion", "bash"}); err != nil || buf.Len() == 0 {
+		t.Fatal("error", err)
+	}
+	err = fset.Run([]string{"clone", "-h"})
+	if IsShowUsage(err) == nil || errors.As(err, &missing) {
+		t.Fatal("error", err)
+	}
+}
+
+type completeFlagRoot struct {
+	Verbose bool               `name:"v"`
+	Clone   *completeFlagClone `flagly:"handler"`
+}
+
+type completeFlagClone struct {
+	Quiet    bool   `name:"q"`
+	Template string `name:"t" long:"template" select:"default,empty"`
+	Repo     string `type:"[0]" select:"github,gitlab"`
+}
+
+func (completeFlagClone) FlaglyHandle() error { return nil }
+
+func TestCompleteFlags(t *testing.T) {
+	fset, err := Compile("app", &completeFlagRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := fset.Completer()
+	for _, c := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"-"}, []string{"-v"}},
+		{[]string{"-v", "cl"}, []string{"clone"}},
+		{[]string{"clone", "-"}, []string{"-q", "-t", "--template", "-h", "--help"}},
+		{[]string{"clone", "--t"}, []string{"--template"}},
+		{[]string{"clone", "-t", ""}, []string{"default", "empty"}},
+		{[]string{"clone", "--template=e"}, []string{"--template=empty"}},
+		{[]string{"clone", "-template", "x", ""}, []string{"github", "gitlab"}},
+		{[]string{"clone", "-q", ""}, []string{"github", "gitlab"}},
+		{[]string{"clone", "--", "-"}, nil},
+	} {
+		if got := hc.Complete(c.words); !reflect.DeepEqual(got, c.want) {
+			t.Fatal("error", c.words, got)
+		}
+	}
+}
+
+type completeArgs struct {
+	Repo  string   `type:"[0]" select:"github,gitlab"`
+	Dir   string   `type:"[1]" complete:"dir"`
+	Files []string `type:"[]" complete:"file"`
+}
+
+func (completeArgs) FlaglyHandle() error { return nil }
+
+func TestCompletePositional(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "file.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644)
+
+	fset := New("app")
+	fset.AddSubHandler("clone", func(*completeArgs) error { return nil })
+	hc := fset.Completer()
+	for _, c := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"clone", ""}, []string{"github", "gitlab"}},
+		{[]string{"clone", "github", dir + "/"}, []string{dir + "/sub/"}},
+		{[]string{"clone", "-h", "github", dir + "/", dir + "/f"}, []string{dir + "/file.txt"}},
+		{[]string{"clone", "a", "b", "c", dir + "/"}, []string{dir + "/file.txt", dir + "/sub/"}},
+	} {
+		if got := hc.Complete(c.words); !reflect.DeepEqual(got, c.want) {
+			t.Fatal("error", c.words, got)
+		}
+	}
+}
+
+type branchName string
+
+type branchParser struct{}
+
+func (branchParser) Type() reflect.Type { return reflect.TypeOf(branchName("")) }
+func (branchParser) ParseArgs(args []string) (reflect.Value, error) {
+	return reflect.ValueOf(branchName(args[0])), nil
+}
+
+func (branchName) Complete(prefix string, h *Handler, partial reflect.Value) []Candidate {
+	remote := partial.Interface().(*completeTyped).Remote
+	return []Candidate{
+		{Value: remote + "/master", Desc: "default branch"},
+		{Value: remote + "/" + prefix + "-wip"},
+	}
+}
+
+type completeTyped struct {
+	Remote string     `name:"remote"`
+	Branch branchName `type:"[0]"`
+	Target string     `type:"[1]"`
+}
+
+func (c *completeTyped) FlaglyComplete(op *Option, prefix string) []Candidate {
+	if op.Name != "target" {
+		return nil
+	}
+	return []Candidate{{Value: string(c.Branch) + "-target"}}
+}
+
+func TestCompleter(t *testing.T) {
+	RegisterAll(branchParser{})
+	fset := New("app")
+	fset.AddSubHandler("checkout", func(*completeTyped) error { return nil })
+	hc := fset.Completer()
+
+	cands := hc.CompleteCandidates([]string{"checkout", "-remote", "origin", "origin/"})
+	want := []Candidate{
+		{Value: "origin/master", Desc: "default branch"},
+		{Value: "origin/origin/-wip"},
+	}
+	if !reflect.DeepEqual(cands, want) {
+		t.Fatal("error", cands)
+	}
+	if got := hc.Complete([]string{"checkout", "dev", ""}); !reflect.DeepEqual(got, []string{"dev-target"}) {
+		t.Fatal("error", got)
+	}
+}
@@ -0,0 +1,245 @@
+package flagly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile holds the option values loaded from a config file, the
+// sections mirror the handler tree:
+//
+//	verbose = true        # options of the root handler
+//
+//	[clone]               # options of the `clone` handler
+//	template = "dir"
+//
+//	[remote.add]          # options of `remote add`
+//
+// JSON files use nested objects as sections instead. All the values are
+// kept as strings and go through the Typer of the options.
+type ConfigFile struct {
+	sections map[string]map[string][]string
+}
+
+func NewConfigFile() *ConfigFile {
+	return &ConfigFile{
+		sections: make(map[string]map[string][]string),
+	}
+}
+
+// LoadConfigFile parses the file as JSON if it ends with `.json`,
+// or INI/TOML-style otherwise.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "ini"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	cfg, err := ParseConfigFile(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfigFile parses r in format "json", "ini" or "toml", the last
+// two are the same here.
+func ParseConfigFile(r io.Reader, format string) (*ConfigFile, error) {
+	cfg := NewConfigFile()
+	switch strings.ToLower(format) {
+	case "json":
+		return cfg, cfg.parseJSON(r)
+	case "ini", "toml":
+		return cfg, cfg.parseINI(r)
+	default:
+		return nil, fmt.Errorf("unknown config format: %v", format)
+	}
+}
+
+// Set appends a value of the key in the section of path
+func (c *ConfigFile) Set(path []string, key string, value string) {
+	name := strings.Join(path, ".")
+	section := c.sections[name]
+	if section == nil {
+		section = make(map[string][]string)
+		c.sections[name] = section
+	}
+	section[key] = append(section[key], value)
+}
+
+// Lookup returns the values of the key in the section of path
+func (c *ConfigFile) Lookup(path []string, key string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	vals, ok := c.sections[strings.Join(path, ".")][key]
+	return vals, ok
+}
+
+func (c *ConfigFile) parseJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return err
+	}
+	return c.setJSONSection(nil, obj)
+}
+
+func (c *ConfigFile) setJSONSection(path []string, obj map[string]interface{}) error {
+	for key, val := range obj {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			sub := append(append([]string{}, path...), key)
+			if err := c.setJSONSection(sub, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			for _, elem := range v {
+				s, err := jsonScalar(elem)
+				if err != nil {
+					return fmt.Errorf("%v: %w", key, err)
+				}
+				c.Set(path, key, s)
+			}
+		case nil:
+		default:
+			s, err := jsonScalar(v)
+			if err != nil {
+				return fmt.Errorf("%v: %w", key, err)
+			}
+			c.Set(path, key, s)
+		}
+	}
+	return nil
+}
+
+func jsonScalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value: %v", v)
+	}
+}
+
+func (c *ConfigFile) parseINI(r io.Reader) error {
+	var path []string
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if IsWrapBy(line, "[]") {
+			path = strings.FieldsFunc(line[1:len(line)-1], func(r rune) bool {
+				return r == '.' || r == ' ' || r == '\t'
+			})
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			return fmt.Errorf("line %v: missing '=': %v", lineNo, line)
+		}
+		key := strings.TrimSpace(line[:idx])
+		vals, err := parseINIValue(strings.TrimSpace(line[idx+1:]))
+		if err != nil {
+			return fmt.Errorf("line %v: %w", lineNo, err)
+		}
+		for _, val := range vals {
+			c.Set(path, key, val)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseINIValue parses `value`, `"quoted"`, `'literal'` and `[a, "b"]`
+func parseINIValue(s string) ([]string, error) {
+	if IsWrapBy(s, "[]") {
+		var ret []string
+		for _, elem := range splitINIArray(s[1 : len(s)-1]) {
+			vals, err := parseINIValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, vals...)
+		}
+		return ret, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		end := closingQuote(s)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string: %v", s)
+		}
+		val, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		return []string{val}, nil
+	}
+	if strings.HasPrefix(s, "'") {
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string: %v", s)
+		}
+		return []string{s[1 : end+1]}, nil
+	}
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+	return []string{s}, nil
+}
+
+// closingQuote returns the index of the quote which closes s[0]
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func splitINIArray(s string) []string {
+	var ret []string
+	start := 0
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == ',':
+			ret = append(ret, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		ret = append(ret, last)
+	}
+	return ret
+}
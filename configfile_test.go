@@ -0,0 +1,196 @@
+package flagly
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	cfg, err := ParseConfigFile(strings.NewReader(`
+# comment
+verbose = true
+name = "hello # world"
+
+[clone]
+template = 'dir'
+tags = [a, "b,c"]
+
+[remote.add]
+url = http://example.com # comment
+`), "ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path []string
+		key  string
+		want []string
+	}{
+		{nil, "verbose", []string{"true"}},
+		{nil, "name", []string{"hello # world"}},
+		{[]string{"clone"}, "template", []string{"dir"}},
+		{[]string{"clone"}, "tags", []string{"a", "b,c"}},
+		{[]string{"remote", "add"}, "url", []string{"http://example.com"}},
+	} {
+		vals, ok := cfg.Lookup(c.path, c.key)
+		if !ok || !reflect.DeepEqual(vals, c.want) {
+			t.Fatal("error", c.key, vals)
+		}
+	}
+
+	cfg, err = ParseConfigFile(strings.NewReader(`{
+		"port": 8080, "debug": false,
+		"clone": {"template": "dir", "tags": ["a", "b"]}
+	}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals, _ := cfg.Lookup(nil, "port"); !reflect.DeepEqual(vals, []string{"8080"}) {
+		t.Fatal("error", vals)
+	}
+	if vals, _ := cfg.Lookup([]string{"clone"}, "tags"); !reflect.DeepEqual(vals, []string{"a", "b"}) {
+		t.Fatal("error", vals)
+	}
+}
+
+type configRoot struct {
+	Port  int          `name:"port" default:"80"`
+	Name  string       `name:"name" default:"default"`
+	Clone *configClone `flagly:"handler"`
+}
+
+type configClone struct {
+	Template string   `name:"t" long:"template"`
+	Tags     []string `name:"tag"`
+}
+
+func TestConfigFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	err := os.WriteFile(path, []byte(`
+port = 8080
+name = file
+[clone]
+template = dir
+tag = [a, b]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset, err := Compile("app", &configRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.EnableConfigFlag()
+	fset.SetEnvPrefix("APP")
+	if !strings.Contains(fset.Usage(), "-config <path>") {
+		t.Fatal("error", fset.Usage())
+	}
+
+	var clone *configClone
+	fset.GetHandler("clone").SetHandleFunc(func(c *configClone) error {
+		clone = c
+		return nil
+	})
+	err = fset.Run([]string{"-config", path, "clone", "-tag", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Template != "dir" || !reflect.DeepEqual(clone.Tags, []string{"c"}) {
+		t.Fatal("error", clone)
+	}
+
+	// the config of -config is only for its run
+	var opt configRoot
+	if err := fset.Bind(reflect.ValueOf(&opt), nil); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 80 || opt.Name != "default" {
+		t.Fatal("error", opt)
+	}
+
+	if err := fset.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := fset.Bind(reflect.ValueOf(&opt), nil); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 8080 || opt.Name != "file" {
+		t.Fatal("error", opt)
+	}
+
+	// flag > env > file > default
+	t.Setenv("APP_NAME", "env")
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-port", "90"}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 90 || opt.Name != "env" {
+		t.Fatal("error", opt)
+	}
+}
+
+func TestConfigFlagConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	fset, err := Compile("app", &configRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.EnableConfigFlag()
+	fset.GetHandler("clone").SetHandleFunc(func(*configClone) error { return nil })
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%v.conf", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("port = %v\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e, err := fset.RunEffective([]string{"-config", path, "clone", "-tag", "a"})
+			if err == nil && e.Map()["port"].Value != i {
+				err = fmt.Errorf("got the values of another run: %v", e)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type configOwnRoot struct {
+	Config string `name:"config" desc:"the user's config"`
+	Port   int    `name:"port" default:"80"`
+}
+
+func TestConfigFlagOwnOption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(path, []byte("port = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fset, err := Compile("app", &configOwnRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.EnableConfigFlag()
+	if n := strings.Count(fset.Usage(), "-config"); n != 1 {
+		t.Fatal("error", fset.Usage())
+	}
+	var opt configOwnRoot
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Config != path || opt.Port != 80 {
+		t.Fatal("error", opt)
+	}
+}
@@ -15,7 +15,7 @@ type GitClone struct {
 	Progress bool   `name:"progress" desc:"force progress reporting"`
 	Template string `arg:"template-directory"`
 
-	Repo string `type:"[0]"`
+	Repo string `type:"[0]" required:"true"`
 	Dir  string `type:"[1]" default:"."`
 }
 
@@ -24,10 +24,6 @@ func (g *GitClone) FlaglyInit() {
 }
 
 func (g *GitClone) FlaglyHandle() error {
-	if g.Repo == "" {
-		return flagly.Error("error: repo is empty")
-	}
-
 	fmt.Printf("git clone\n    %+v\n    %+v\n", g.Parent, g)
 	return nil
 }
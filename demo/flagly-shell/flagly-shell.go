@@ -13,17 +13,25 @@ usage:
     usage: time [option] [--] [<layout>]
 
     options:
-        -h                  show help
+        layout
+        -h, --help          show help
     > base64
-    missing content
+    missing required options: <content>
 
     usage: base64 [option] [--] <content>
 
     options:
         -d                  decode string
-        -h                  show help
+        content             (required)
+        -h, --help          show help
     > base64 hello
     aGVsbG8=
+    > use level1
+    level1> help
+    usage: level1 <command>
+    ...
+    level1> ..
+    >
 */
 package main
 
@@ -34,8 +42,6 @@ import (
 	"time"
 
 	"github.com/chzyer/flagly"
-	"github.com/chzyer/readline"
-	"github.com/google/shlex"
 )
 
 type Help struct{}
@@ -58,13 +64,10 @@ func (t *Time) FlaglyHandle() error {
 
 type Base64 struct {
 	IsDecode bool   `name:"d" desc:"decode string"`
-	Content  string `type:"[0]"`
+	Content  string `type:"[0]" required:"true"`
 }
 
 func (b *Base64) FlaglyHandle() error {
-	if b.Content == "" {
-		return flagly.Error("missing content")
-	}
 	if b.IsDecode {
 		ret, err := base64.URLEncoding.DecodeString(b.Content)
 		if err != nil {
@@ -98,39 +101,17 @@ type Level2 struct {
 type Level3 struct{}
 
 func main() {
-	rl, err := readline.NewEx(&readline.Config{
-		Prompt:      "> ",
-		HistoryFile: "/tmp/flagly-shell.readline",
-	})
+	var p Program
+	fset, err := flagly.Compile("", &p)
 	if err != nil {
 		println(err.Error())
 		os.Exit(1)
 	}
-	defer rl.Close()
-
-	var p Program
-	fset, err := flagly.Compile("", &p)
+	err = fset.Interactive(&flagly.InteractiveConfig{
+		HistoryFile: "/tmp/flagly-shell.readline",
+	})
 	if err != nil {
 		println(err.Error())
 		os.Exit(1)
 	}
-	rl.Config.AutoComplete = &readline.SegmentComplete{fset.Completer()}
-
-	for {
-		line, err := rl.Readline()
-		if err != nil {
-			break
-		}
-		if line == "" {
-			continue
-		}
-		command, err := shlex.Split(line)
-		if err != nil {
-			println("error: " + err.Error())
-			continue
-		}
-		if err := fset.Run(command); err != nil {
-			println(err.Error())
-		}
-	}
 }
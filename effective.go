@@ -0,0 +1,207 @@
+package flagly
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const redacted = "******"
+
+// Source is where the value of an option comes from
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceFlag
+	SourceEnv
+	SourceFile
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFlag:
+		return "flag"
+	case SourceEnv:
+		return "env"
+	case SourceFile:
+		return "file"
+	default:
+		return "<unknown>"
+	}
+}
+
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// EffectiveValue is the resolved value of an option
+type EffectiveValue struct {
+	// the path of the handler without the root
+	Path   []string    `json:"-"`
+	Name   string      `json:"-"`
+	Value  interface{} `json:"value"`
+	Source Source      `json:"source"`
+	Secret bool        `json:"secret,omitempty"`
+
+	// the raw values of each occurrence, nil if Source is SourceDefault
+	raw    [][]string
+	option *Option
+}
+
+// Key is the name of the value in Effective.Map, e.g. `clone.template`
+func (v EffectiveValue) Key() string {
+	return strings.Join(append(append([]string{}, v.Path...), v.Name), ".")
+}
+
+// Effective is the resolved option values of the handlers which ran
+type Effective struct {
+	// names of the handlers from the root
+	Path   []string
+	Values []EffectiveValue
+
+	// the handlers of Path
+	handlers []*Handler
+}
+
+// enter records the handler which runs, whether it has options or not
+func (e *Effective) enter(h *Handler) {
+	e.Path = append(e.Path, h.Name)
+	e.handlers = append(e.handlers, h)
+}
+
+func (e *Effective) add(h *Handler, v reflect.Value, ops []*Option, sources []Source, raws [][][]string) {
+	path := h.subPath()
+	for idx, op := range ops {
+		if op.Index < 0 || !v.IsValid() {
+			continue
+		}
+		val := EffectiveValue{
+			Path:   path,
+			Name:   op.Name,
+			Source: sources[idx],
+			Secret: op.Secret,
+			raw:    raws[idx],
+			option: op,
+		}
+		if op.HasLongName() {
+			val.Name = op.LongName
+		}
+		if op.Secret {
+			val.Value = redacted
+		} else {
+			val.Value = v.Elem().Field(op.Index).Interface()
+		}
+		e.Values = append(e.Values, val)
+	}
+}
+
+// update overrides the value of op by a flag given to a sub handler
+func (e *Effective) update(v reflect.Value, op *Option, vals []string) {
+	for idx := range e.Values {
+		val := &e.Values[idx]
+		if val.option != op {
+			continue
+		}
+		if !op.Secret {
+			val.Value = v.Elem().Field(op.Index).Interface()
+		}
+		if val.Source != SourceFlag || !op.accumulates() {
+			val.raw = nil
+		}
+		val.Source = SourceFlag
+		val.raw = append(val.raw, vals)
+	}
+}
+
+// Map returns the values keyed by EffectiveValue.Key()
+func (e *Effective) Map() map[string]EffectiveValue {
+	ret := make(map[string]EffectiveValue, len(e.Values))
+	for _, v := range e.Values {
+		ret[v.Key()] = v
+	}
+	return ret
+}
+
+func (e *Effective) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Map())
+}
+
+// FlagLine returns a command line which runs the same handler with the
+// same options, the values from defaults are omitted.
+func (e *Effective) FlagLine() string {
+	var words []string
+	for _, h := range e.handlers {
+		if h.Name != "" {
+			words = append(words, shellQuote(h.Name))
+		}
+		var positional []string
+		for _, v := range e.Values {
+			if v.option.handler != h || v.Source == SourceDefault {
+				continue
+			}
+			for _, raw := range v.raw {
+				if v.Secret {
+					raw = []string{redacted}
+				}
+				quoted := make([]string, len(raw))
+				for idx, r := range raw {
+					quoted[idx] = shellQuote(r)
+				}
+				if v.option.IsArg() {
+					positional = append(positional, quoted...)
+					continue
+				}
+				flag := v.option.DisplayName()
+				if _, ok := v.option.Typer.(Count); ok && len(raw) == 1 {
+					// a count from env or config file, it never takes a
+					// value from the command line
+					n, _ := strconv.Atoi(raw[0])
+					for i := 0; i < n; i++ {
+						words = append(words, flag)
+					}
+					continue
+				}
+				switch len(raw) {
+				case 0:
+					words = append(words, flag)
+				case 1:
+					words = append(words, flag+"="+quoted[0])
+				default:
+					words = append(words, flag)
+					words = append(words, quoted...)
+				}
+			}
+		}
+		for _, p := range positional {
+			if strings.HasPrefix(p, "-") {
+				words = append(words, "--")
+				break
+			}
+		}
+		words = append(words, positional...)
+	}
+	return strings.Join(words, " ")
+}
+
+func (e *Effective) String() string {
+	return fmt.Sprintf("%v", e.Map())
+}
+
+// shellQuote quotes s with single quotes if it contains special characters
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || strings.ContainsRune("-_=.,/:@%+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,97 @@
+package flagly
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type effectiveRoot struct {
+	Verbose bool            `name:"v"`
+	Token   string          `name:"token" secret:"true"`
+	User    string          `name:"user" desc:"not a secret" default:"me"`
+	Host    string          `name:"host" secret:"false" default:"h"`
+	Clone   *effectiveClone `flagly:"handler"`
+}
+
+type effectiveClone struct {
+	Template string   `name:"t" long:"template" default:"tpl"`
+	Tags     []string `name:"tag"`
+	Depth    int      `name:"depth"`
+	Repo     string   `type:"[0]"`
+}
+
+func (effectiveClone) FlaglyHandle() error { return nil }
+
+func TestEffective(t *testing.T) {
+	fset, err := Compile("app", &effectiveRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.SetEnvPrefix("APP")
+	t.Setenv("APP_CLONE_DEPTH", "3")
+	e, err := fset.RunEffective([]string{"-v", "-token", "xyz", "clone", "-tag", "a", "-tag=b c", "repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(e.Path, " ") != "app clone" {
+		t.Fatal("error", e.Path)
+	}
+	m := e.Map()
+	if v := m["v"]; v.Value != true || v.Source != SourceFlag {
+		t.Fatal("error", v)
+	}
+	if v := m["token"]; v.Value != redacted {
+		t.Fatal("error", v)
+	}
+	if v := m["user"]; v.Value != "me" || v.Secret {
+		t.Fatal("error", v)
+	}
+	if v := m["host"]; v.Value != "h" || v.Secret {
+		t.Fatal("error", v)
+	}
+	if v := m["clone.template"]; v.Value != "tpl" || v.Source != SourceDefault {
+		t.Fatal("error", v)
+	}
+	if v := m["clone.tag"]; !reflect.DeepEqual(v.Value, []string{"a", "b c"}) {
+		t.Fatal("error", v)
+	}
+	if v := m["clone.depth"]; v.Value != 3 || v.Source != SourceEnv {
+		t.Fatal("error", v)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"clone.depth":{"value":3,"source":"env"}`) {
+		t.Fatal("error", string(data))
+	}
+
+	line := e.FlagLine()
+	if line != "app -v -token='******' clone -tag=a -tag='b c' -depth=3 repo" {
+		t.Fatal("error", line)
+	}
+}
+
+func TestEffectiveNoOptions(t *testing.T) {
+	fset := New("app")
+	grp := NewHandler("remote")
+	fset.Add(grp)
+	grp.AddSubHandler("add", func(*struct {
+		N int `name:"n"`
+	}) error {
+		return nil
+	})
+	e, err := fset.RunEffective([]string{"remote", "add", "-n", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(e.Path, " ") != "app remote add" {
+		t.Fatal("error", e.Path)
+	}
+	if line := e.FlagLine(); line != "app remote add -n=3" {
+		t.Fatal("error", line)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 	"sync"
@@ -103,6 +104,112 @@ func (f *FlaglySet) Lambda(name string, fn func() []string) {
 	f.subHandler.Lambda(name, fn)
 }
 
+// SetBundling enables parsing a cluster of single-letter flags,
+// `-xvf file.tar` is the same as `-x -v -f file.tar`.
+func (f *FlaglySet) SetBundling(enable bool) {
+	f.subHandler.setting.bundling = enable
+}
+
+// SetStrict(false) makes the undefined flags to be ignored silently,
+// by default they are rejected with an *UnknownFlagError.
+func (f *FlaglySet) SetStrict(strict bool) {
+	f.subHandler.setting.allowUnknown = !strict
+}
+
+// SetSuggestDistance sets how many edits away a command or a flag can be to
+// be suggested for a mistyped one, 0 disables the suggestions.
+func (f *FlaglySet) SetSuggestDistance(n int) {
+	f.subHandler.setting.suggestDistance = n
+}
+
+// SetPrefixMatching makes a command can be given by a unique prefix of its
+// name or aliases, e.g. `cl` for `clone`, an ambiguous prefix is rejected
+// with an *AmbiguousCommandError.
+func (f *FlaglySet) SetPrefixMatching(enable bool) {
+	f.subHandler.setting.prefixMatch = enable
+}
+
+// SetInterspersed makes the leaf handlers accept the flags anywhere before
+// `--`, e.g. `ls a -l b` is the same as `ls -l a b`. It can be enabled for
+// a single handler by `flagly:"handler,interspersed"`.
+func (f *FlaglySet) SetInterspersed(enable bool) {
+	f.subHandler.setting.interspersed = enable
+}
+
+// SetEnvPrefix makes every flag fallback to an environment variable named
+// by the prefix, the handler path and the flag name, e.g. APP_CLONE_TEMPLATE.
+// The value of a flag is looked up in the order of command line,
+// environment variable and then `default` tag.
+func (f *FlaglySet) SetEnvPrefix(prefix string) {
+	f.subHandler.setting.envPrefix = prefix
+}
+
+// EnableConfigFlag adds a `-config <path>` flag to the root handler, the
+// options are loaded from the file when it's given, see ConfigFile. It's
+// not added if the root handler has its own `config` option.
+func (f *FlaglySet) EnableConfigFlag() {
+	f.subHandler.setting.configFlag = true
+	f.subHandler.EnsureHelpOption()
+}
+
+// LoadConfig loads option values from a config file, the values are
+// overridden by the environment variables and the command line flags.
+func (f *FlaglySet) LoadConfig(path string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	f.subHandler.setting.config = cfg
+	return nil
+}
+
+// SetConfig is like LoadConfig but uses a parsed config file
+func (f *FlaglySet) SetConfig(cfg *ConfigFile) {
+	f.subHandler.setting.config = cfg
+}
+
+// SetOutput sets where the builtin commands write to, default is os.Stdout
+func (f *FlaglySet) SetOutput(w io.Writer) {
+	f.subHandler.setting.output = w
+}
+
+// TypeRegistry returns the typers used by Compile, the typers registered
+// to it don't affect the other FlaglySets. It inherits from the default
+// registry of Register and RegisterAll.
+func (f *FlaglySet) TypeRegistry() *TypeRegistry {
+	return f.subHandler.setting.types
+}
+
+// SetTypeRegistry replaces the typers used by Compile, it must be called
+// before Compile.
+func (f *FlaglySet) SetTypeRegistry(r *TypeRegistry) {
+	f.subHandler.setting.types = r
+}
+
+// SetWarningOutput sets where the warnings of the deprecated options and
+// commands write to, default is os.Stderr, nil to discard them.
+func (f *FlaglySet) SetWarningOutput(w io.Writer) {
+	f.subHandler.setting.warnOutput = w
+}
+
+// SetContinueOnError makes RunScript run the rest of the lines after a line
+// fails, by default it stops at the first error.
+func (f *FlaglySet) SetContinueOnError(enable bool) {
+	f.subHandler.setting.continueOnError = enable
+}
+
+// EnableCompletion adds the `completion <bash|zsh|fish>` command which
+// prints the completion script, and a hidden `__complete` command which is
+// called by the script, it runs without parsing the root options.
+func (f *FlaglySet) EnableCompletion() {
+	if f.GetHandler("completion") == nil {
+		f.Add(NewHandlerCompletion())
+	}
+	if f.GetHandler("__complete") == nil {
+		f.Add(NewHandlerComplete())
+	}
+}
+
 func (f *FlaglySet) Compile(target interface{}) error {
 	return f.subHandler.Compile(reflect.TypeOf(target))
 }
@@ -120,19 +227,43 @@ func (f *FlaglySet) SetHandleFunc(hf interface{}) {
 }
 
 func (f *FlaglySet) Bind(value reflect.Value, args []string) error {
-	return f.subHandler.Bind(value, args)
+	_, err := f.BindEffective(value, args)
+	return err
+}
+
+// BindEffective is like Bind but also returns the option values resolved
+// by it, along with where each of them comes from.
+func (f *FlaglySet) BindEffective(value reflect.Value, args []string) (*Effective, error) {
+	st := f.subHandler.setting.newRun()
+	err := f.subHandler.bind(st, value, args)
+	return st.effective, err
 }
 
 func (f *FlaglySet) Run(args []string) (err error) {
 	return f.RunWithContext(args, nil)
 }
 
+// RunEffective is like Run but also returns the option values resolved by
+// the handlers which ran, along with where each of them comes from.
+func (f *FlaglySet) RunEffective(args []string) (*Effective, error) {
+	st := f.subHandler.setting.newRun()
+	err := f.run(st, args, nil)
+	return st.effective, err
+}
+
 func (f *FlaglySet) RunWithContext(args []string, context map[string]reflect.Value) (err error) {
+	return f.run(f.subHandler.setting.newRun(), args, context)
+}
+
+func (f *FlaglySet) run(st *runState, args []string, context map[string]reflect.Value) error {
 	stack := []reflect.Value{}
-	if err = f.subHandler.Run(&stack, args, context); err != nil {
-		return err
+	if len(args) > 0 && args[0] == "__complete" {
+		// the root options are not parsed, they may be incomplete
+		if h := f.subHandler.GetHandler("__complete"); h != nil {
+			return h.run(st, &stack, args[1:], context)
+		}
 	}
-	return
+	return f.subHandler.run(st, &stack, args, context)
 }
 
 func (f *FlaglySet) GetHandler(name string) *Handler {
@@ -21,9 +21,23 @@ type Handler struct {
 	Name     string
 	Desc     string
 	Children []*Handler
+	// other names of the handler, e.g. `co` for `checkout`
+	Aliases []string
+	// hidden handlers still run but are not listed in usage and completion
+	Hidden bool
+	// the message of the warning printed when the handler runs
+	Deprecated string
+	// the flags can be mixed with the arguments, e.g. `ls a -l b`,
+	// it only works on the leaf handlers.
+	Interspersed bool
+
+	// the required options of the ancestors are not checked when it runs,
+	// e.g. the builtin commands
+	ignoreRequired bool
 
 	context       map[string]reflect.Value
 	lambdaMap     map[string]func() []string
+	setting       *setting
 	Options       []*Option
 	OptionType    reflect.Type
 	handleFunc    reflect.Value
@@ -36,6 +50,7 @@ func NewHandler(name string) *Handler {
 		Name:      name,
 		context:   make(map[string]reflect.Value),
 		lambdaMap: make(map[string]func() []string),
+		setting:   newSetting(),
 	}
 	return h
 }
@@ -51,6 +66,27 @@ func (h *Handler) GetName() string {
 	return h.Name
 }
 
+// subPath returns the names of the handlers from the root to h, the root
+// itself is excluded.
+func (h *Handler) subPath() []string {
+	if h.Parent == nil {
+		return nil
+	}
+	return append(h.Parent.subPath(), h.Name)
+}
+
+// Path returns the names of the handlers from the root to h
+func (h *Handler) Path() []string {
+	var path []string
+	if h.Parent != nil {
+		path = h.Parent.Path()
+	}
+	if h.Name != "" {
+		path = append(path, h.Name)
+	}
+	return path
+}
+
 func (h *Handler) findArgOption() *Option {
 	for _, op := range h.Options {
 		if op.IsArg() {
@@ -60,9 +96,27 @@ func (h *Handler) findArgOption() *Option {
 	return nil
 }
 
+// findArgOptionAt returns the arg option at the position, the variadic
+// one `[]` matches any position which is not declared.
+func (h *Handler) findArgOptionAt(pos int) *Option {
+	var variadic *Option
+	for _, op := range h.Options {
+		if !op.IsArg() {
+			continue
+		}
+		if op.ArgIdx == pos {
+			return op
+		}
+		if op.ArgIdx == -1 {
+			variadic = op
+		}
+	}
+	return variadic
+}
+
 func (h *Handler) GetTreeChildren() []Tree {
-	children := h.GetChildren()
-	if len(children) == 0 {
+	children := h.visibleChildren()
+	if len(h.GetChildren()) == 0 {
 		argOp := h.findArgOption()
 		if argOp != nil {
 			return argOp.GetTree(h.lambdaMap)
@@ -86,6 +140,9 @@ func (h *Handler) ResetHandler() {
 // combine NewHander(name).SetHanderFunc()/AddHandler(subHandler)
 func (h *Handler) AddSubHandler(name string, function interface{}) *Handler {
 	subHandler := NewHandler(name)
+	// compile with the typers of the tree
+	subHandler.Parent = h
+	subHandler.setting = h.setting
 	subHandler.SetHandleFunc(function)
 	h.AddHandler(subHandler)
 	return subHandler
@@ -95,6 +152,7 @@ func (h *Handler) copyContext() {
 	for _, ch := range h.Children {
 		ch.context = h.context
 		ch.lambdaMap = h.lambdaMap
+		ch.setting = h.setting
 		ch.copyContext()
 	}
 }
@@ -103,13 +161,33 @@ func (h *Handler) Lambda(name string, fn func() []string) {
 	h.lambdaMap[name] = fn
 }
 
+// AddHandler adds child to h, the options of child are compiled again if
+// they were compiled with the typers of another tree.
 func (h *Handler) AddHandler(child *Handler) {
+	recompile := child.setting != h.setting
 	child.Parent = h
 	h.Children = append(h.Children, child)
 	h.copyContext()
+	if recompile {
+		child.recompile()
+	}
 	child.EnsureHelpOption()
 }
 
+// recompile resolves the typers of the options of h and its children with
+// the typers of the tree, the options are kept as they are. It panics like
+// SetHandleFunc.
+func (h *Handler) recompile() {
+	for _, op := range h.Options {
+		if err := op.retype(h.setting.types); err != nil {
+			panic(err)
+		}
+	}
+	for _, ch := range h.Children {
+		ch.recompile()
+	}
+}
+
 func (h *Handler) SetGetChildren(f func(*Handler) []*Handler) {
 	h.onGetChildren = f
 }
@@ -198,6 +276,10 @@ func (h *Handler) EnsureHelpOption() {
 }
 
 func (h *Handler) tryToAddHelpOption(ops []*Option) []*Option {
+	if h.Parent == nil && h.setting.configFlag && findConfigOption(ops) == nil &&
+		!hasOptionName(ops, "config") {
+		ops = append(ops, NewConfigFlag("config"))
+	}
 	if len(h.GetChildren()) == 0 {
 		hasHelp := false
 		for _, op := range ops {
@@ -213,6 +295,26 @@ func (h *Handler) tryToAddHelpOption(ops []*Option) []*Option {
 	return ops
 }
 
+// hasOptionName reports whether name is the short or the long name of any
+// of the options
+func hasOptionName(ops []*Option, name string) bool {
+	for _, op := range ops {
+		if op.Name == name || op.LongName == name && op.HasLongName() {
+			return true
+		}
+	}
+	return false
+}
+
+func findConfigOption(ops []*Option) *Option {
+	for _, op := range ops {
+		if op.loadConfig {
+			return op
+		}
+	}
+	return nil
+}
+
 func (h *Handler) CompileIface(obj interface{}) error {
 	return h.Compile(reflect.TypeOf(obj))
 }
@@ -261,6 +363,15 @@ func (h *Handler) Compile(t reflect.Type) error {
 				name = strings.ToLower(field.Name)
 			}
 			subh := NewHandler(name)
+			// compile with the typers of the tree
+			subh.Parent = h
+			subh.setting = h.setting
+			subh.Interspersed = tag.FlaglyHas("interspersed")
+			if alias := tag.Get("alias"); alias != "" {
+				subh.Aliases = strings.Split(alias, ",")
+			}
+			subh.Hidden = tag.Bool("hidden")
+			subh.Deprecated = tag.Get("deprecated")
 			if err := subh.Compile(field.Type); err != nil {
 				return err
 			}
@@ -277,17 +388,27 @@ func (h *Handler) HasDesc() bool {
 	return h.Desc != ""
 }
 
+// names returns the name and the aliases
+func (h *Handler) names() []string {
+	return append([]string{h.Name}, h.Aliases...)
+}
+
 func (h *Handler) writeDesc(buf *bytes.Buffer) {
 	prefix := "    "
 	space := 20
-	if len(h.Name) > space {
-		buf.WriteString(prefix + h.Name + "\n")
-		if h.HasDesc() {
-			buf.WriteString(strings.Repeat(" ", space) + h.Desc + "\n")
+	name := strings.Join(h.names(), ", ")
+	desc := h.Desc
+	if h.Deprecated != "" {
+		desc = strings.TrimSpace(desc + " (deprecated)")
+	}
+	if len(name) > space {
+		buf.WriteString(prefix + name + "\n")
+		if desc != "" {
+			buf.WriteString(strings.Repeat(" ", space) + desc + "\n")
 		}
 	} else {
-		indent := strings.Repeat(" ", space-len(h.Name))
-		buf.WriteString(prefix + h.Name + indent + h.Desc + "\n")
+		indent := strings.Repeat(" ", space-len(name))
+		buf.WriteString(prefix + name + indent + desc + "\n")
 	}
 }
 
@@ -302,15 +423,68 @@ func (h *Handler) GetChildren() []*Handler {
 	return h.Children
 }
 
+// GetHandler returns the child by its name or one of its aliases
 func (h *Handler) GetHandler(name string) *Handler {
-	for _, ch := range h.GetChildren() {
+	children := h.GetChildren()
+	for _, ch := range children {
 		if ch.Name == name {
 			return ch
 		}
 	}
+	for _, ch := range children {
+		for _, alias := range ch.Aliases {
+			if alias == name {
+				return ch
+			}
+		}
+	}
 	return nil
 }
 
+// findChild is like GetHandler but also matches a unique prefix of the
+// names if prefix matching is enabled, it returns nil if no child matches.
+func (h *Handler) findChild(name string) (*Handler, error) {
+	if ch := h.GetHandler(name); ch != nil || !h.setting.prefixMatch || h.HasArgOptions() {
+		return ch, nil
+	}
+	var (
+		matched *Handler
+		names   []string
+	)
+	for _, ch := range h.visibleChildren() {
+		for _, n := range ch.names() {
+			if strings.HasPrefix(n, name) {
+				if matched != ch {
+					names = append(names, ch.Name)
+				}
+				matched = ch
+				break
+			}
+		}
+	}
+	if len(names) > 1 {
+		return nil, usageError(&AmbiguousCommandError{
+			Name:       name,
+			Path:       h.Path(),
+			Candidates: names,
+		})
+	}
+	return matched, nil
+}
+
+// unknownCommand returns the *UnknownCommandError of name
+func (h *Handler) unknownCommand(name string) error {
+	var names [][]string
+	for _, ch := range h.visibleChildren() {
+		names = append(names, ch.names())
+	}
+	return usageError(&UnknownCommandError{
+		Name:        name,
+		Path:        h.Path(),
+		Suggestions: suggestFirst(name, names, h.setting.suggestDistance),
+	})
+}
+
 func (h *Handler) Usage(prefix string) string {
 	buf := bytes.NewBuffer(nil)
 	err := h.usage(buf, prefix)
@@ -333,89 +507,424 @@ func (h *Handler) GetOptionNames() []string {
 	ret := make([]string, 0, len(h.Options))
 	for _, op := range h.Options {
 		ret = append(ret, op.Name)
+		if op.HasLongName() {
+			ret = append(ret, op.LongName)
+		}
 	}
 	return ret
 }
 
+// findOption matches name against both the short and the long name,
+// so `-template` and `--template` resolve to the same option.
 func (h *Handler) findOption(name string) int {
 	for idx, op := range h.Options {
-		if op.Name == name {
+		if op.Name == name || op.LongName == name && op.HasLongName() {
 			return idx
 		}
 	}
 	return -1
 }
 
-func (h *Handler) parseToStruct(v reflect.Value, args []string) ([]string, error) {
-	tokens := make([][]string, len(h.Options))
+func (h *Handler) suggestFlags(arg, name string) []string {
+	prefix := arg[:len(arg)-len(trimFlagName(arg))]
+	var names []string
+	for _, op := range append(append([]*Option{}, h.Options...), h.persistentOptions()...) {
+		if op.Hidden {
+			continue
+		}
+		names = append(names, op.Name)
+		if op.HasLongName() {
+			names = append(names, op.LongName)
+		}
+	}
+	suggestions := suggest(name, names, h.setting.suggestDistance)
+	for idx, s := range suggestions {
+		if len(s) == 1 {
+			suggestions[idx] = "-" + s
+		} else {
+			suggestions[idx] = prefix + s
+		}
+	}
+	return suggestions
+}
+
+// lookupValue looks up the value of the option which is not given in the
+// command line, from the environment variable and then the config file of
+// the run. from describes where the value comes from.
+func (h *Handler) lookupValue(st *runState, op *Option) (vals []string, source Source, from string) {
+	if env := op.EnvName(); env != "" {
+		if val, ok := os.LookupEnv(env); ok {
+			return []string{val}, SourceEnv, "$" + env
+		}
+	}
+	path := h.subPath()
+	for _, name := range []string{op.LongName, op.Name} {
+		if name == "" {
+			continue
+		}
+		if vals, ok := st.config.Lookup(path, name); ok {
+			return vals, SourceFile, "config " + strings.Join(append(path, name), ".")
+		}
+	}
+	return nil, SourceDefault, ""
+}
+
+func canBeValues(t Typer, vals []string) bool {
+	for _, val := range vals {
+		if !t.CanBeValue(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// flagBundle is the option indexes of a cluster of short flags like `-xvf`
+type flagBundle []int
+
+func (b flagBundle) names(h *Handler) []string {
+	names := make([]string, len(b))
+	for idx, opIdx := range b {
+		names[idx] = "-" + h.Options[opIdx].Name
+	}
+	return names
+}
+
+// splitBundle splits name into single-letter flags, returns nil if any of
+// the letters is not a flag. Only the last one of them can take a value,
+// the bundle is returned along with the error otherwise.
+func (h *Handler) splitBundle(name string) (flagBundle, error) {
+	bundle := make(flagBundle, 0, len(name))
+	for _, r := range name {
+		opIdx := h.findOption(string(r))
+		if opIdx < 0 || !h.Options[opIdx].IsFlag() {
+			return nil, nil
+		}
+		bundle = append(bundle, opIdx)
+	}
+	for _, opIdx := range bundle[:len(bundle)-1] {
+		op := h.Options[opIdx]
+		if min, _ := op.Typer.NumArgs(); min > 0 {
+			return bundle, fmt.Errorf("flag %v in -%v requires a value, it must be the last one",
+				op.DisplayName(), name)
+		}
+	}
+	return bundle, nil
+}
+
+// splitCount splits a run of a count flag like `vvv`, it works without
+// bundling. It returns nil if name is not a run of a count flag.
+func (h *Handler) splitCount(name string) flagBundle {
+	opIdx := h.findOption(name[:1])
+	if opIdx < 0 || strings.Trim(name, name[:1]) != "" {
+		return nil
+	}
+	if _, ok := h.Options[opIdx].Typer.(Count); !ok {
+		return nil
+	}
+	bundle := make(flagBundle, len(name))
+	for idx := range bundle {
+		bundle[idx] = opIdx
+	}
+	return bundle
+}
+
+// trimFlagName strips the leading `-` or `--` of a flag
+func trimFlagName(arg string) string {
+	if strings.HasPrefix(arg, "--") {
+		return arg[2:]
+	}
+	return arg[1:]
+}
+
+func (h *Handler) isInterspersed() bool {
+	return (h.Interspersed || h.setting.interspersed) && len(h.GetChildren()) == 0
+}
+
+// warnf prints a warning to the warning output
+func (h *Handler) warnf(format string, obj ...interface{}) {
+	if w := h.setting.warnOutput; w != nil {
+		fmt.Fprintf(w, "warning: "+format+"\n", obj...)
+	}
+}
+
+// warnDeprecated warns the deprecated option which is used
+func (h *Handler) warnDeprecated(op *Option) {
+	if op.Deprecated == "" {
+		return
+	}
+	kind := "flag"
+	if op.IsArg() {
+		kind = "argument"
+	}
+	h.warnf("%v %v is deprecated, %v", kind, op.DisplayName(), op.Deprecated)
+}
+
+func (h *Handler) warnDeprecatedCommand() {
+	if h.Deprecated != "" {
+		h.warnf("command '%v' is deprecated, %v", strings.Join(h.Path(), " "), h.Deprecated)
+	}
+}
+
+// findPersistent finds the persistent flag of the ancestors by name
+func (h *Handler) findPersistent(name string) *Option {
+	for p := h.Parent; p != nil; p = p.Parent {
+		if idx := p.findOption(name); idx >= 0 && p.Options[idx].Persistent {
+			return p.Options[idx]
+		}
+	}
+	return nil
+}
+
+// depth is the index of the handler in the stack
+func (h *Handler) depth() int {
+	if h.Parent == nil {
+		return 0
+	}
+	return h.Parent.depth() + 1
+}
+
+// inheritedFlag is a persistent flag of an ancestor given to a sub handler
+type inheritedFlag struct {
+	op   *Option
+	vals []string
+}
+
+// parseToStruct parses args into v, stack is the option values of the
+// ancestors which the persistent flags are written into.
+func (h *Handler) parseToStruct(st *runState, v reflect.Value, args []string, stack []reflect.Value) ([]string, error) {
+	// the values of each occurrence of the flags
+	tokens := make([][][]string, len(h.Options))
+	var inherited []inheritedFlag
+	// the arguments before the flags if interspersed
+	var positional []string
+	interspersed := h.isInterspersed()
 	idx := 0
 	for ; idx < len(args); idx++ {
 		arg := args[idx]
-		if strings.HasPrefix(arg, "-") {
-			// TODO: flag name can't be -
+		if interspersed && !isFlagSegment(arg) {
+			positional = append(positional, arg)
+			continue
+		}
+		// a bare `-` is a positional, e.g. stdin
+		if isFlagSegment(arg) {
 			if arg == "--" {
-				args = args[idx+1:]
+				idx++
 				break
 			}
-			opIdx := h.findOption(arg[1:])
-			if opIdx < 0 {
-				continue
+			name, value, hasValue := strings.Cut(trimFlagName(arg), "=")
+			opIdx := h.findOption(name)
+			if h.setting.bundling && !strings.HasPrefix(arg, "--") && len(name) > 1 {
+				bundle, err := h.splitBundle(name)
+				if bundle != nil && opIdx >= 0 {
+					return args, fmt.Errorf("ambiguous flag %v: both an option and a bundle of %v",
+						arg, strings.Join(bundle.names(h), " "))
+				}
+				if err != nil {
+					return args, err
+				}
+				if bundle != nil {
+					for _, bIdx := range bundle[:len(bundle)-1] {
+						if h.Options[bIdx].ShowUsage {
+							return args, ErrShowUsage
+						}
+						tokens[bIdx] = append(tokens[bIdx], []string{})
+					}
+					// the last flag in the bundle can take a value
+					opIdx = bundle[len(bundle)-1]
+				}
+			}
+			if opIdx < 0 && !strings.HasPrefix(arg, "--") && len(name) > 1 {
+				if bundle := h.splitCount(name); bundle != nil {
+					for _, bIdx := range bundle[:len(bundle)-1] {
+						tokens[bIdx] = append(tokens[bIdx], []string{})
+					}
+					opIdx = bundle[len(bundle)-1]
+				}
+			}
+			var op *Option
+			if opIdx >= 0 {
+				op = h.Options[opIdx]
+			} else {
+				op = h.findPersistent(name)
+			}
+			if op == nil {
+				if name == "h" || name == "help" {
+					// only the leaf handlers have the help flag
+					return args, ErrShowUsage
+				}
+				if h.setting.allowUnknown {
+					continue
+				}
+				return args, usageError(&UnknownFlagError{
+					Name:        strings.SplitN(arg, "=", 2)[0],
+					Path:        h.Path(),
+					Suggestions: h.suggestFlags(arg, name),
+				})
 			}
-			op := h.Options[opIdx]
 			if op.ShowUsage {
 				return args, ErrShowUsage
 			}
 			min, max := op.Typer.NumArgs()
 			subArgs := make([]string, 0, max)
-			for i := idx + 1; i <= idx+max && i < len(args); i++ {
-				if !op.Typer.CanBeValue(args[i]) {
-					break
+			if hasValue {
+				// -name=value, the value always belongs to the flag
+				if max == 0 {
+					return args, fmt.Errorf("flag %v doesn't take a value: %v", op.DisplayName(), arg)
+				}
+				if !op.Typer.CanBeValue(value) {
+					return args, op.valueError([]string{value}, fmt.Errorf("unexpected value"))
 				}
-				subArgs = append(subArgs, args[i])
+				subArgs = append(subArgs, value)
+			} else {
+				for i := idx + 1; i <= idx+max && i < len(args); i++ {
+					if !op.Typer.CanBeValue(args[i]) {
+						break
+					}
+					subArgs = append(subArgs, args[i])
+				}
+				idx += len(subArgs)
 			}
 			if len(subArgs) < min {
-				return args, fmt.Errorf("args missing")
+				return args, fmt.Errorf("flag %v: args missing", op.DisplayName())
+			}
+			if opIdx >= 0 {
+				tokens[opIdx] = append(tokens[opIdx], subArgs)
+			} else {
+				inherited = append(inherited, inheritedFlag{op, subArgs})
 			}
-			idx += len(subArgs)
-			tokens[opIdx] = subArgs
 			continue
 		}
 		break
 	}
 
-	args = args[idx:]
+	args = append(positional, args[idx:]...)
 
 	for idx, op := range h.Options {
-		var err error
+		if op.loadConfig && tokens[idx] != nil {
+			path := tokens[idx][len(tokens[idx])-1][0]
+			cfg, err := loadConfig(path)
+			if err != nil {
+				return args, err
+			}
+			st.config = cfg
+		}
+	}
+
+	var missing []*Option
+	sources := make([]Source, len(h.Options))
+	raws := make([][][]string, len(h.Options))
+	for idx, op := range h.Options {
+		if op.Index < 0 {
+			continue
+		}
+		// the values of each occurrence
+		var occurs [][]string
 		if op.IsArg() {
 			if op.ArgIdx == -1 {
-				err = op.BindTo(v, args)
-			} else if op.ArgIdx >= len(args) {
-				if op.HasDefault() {
-					err = op.BindTo(v, nil)
-				} else {
-					// do nothing
+				if len(args) > 0 {
+					occurs = [][]string{args}
 				}
-			} else {
-				err = op.BindTo(v, args[op.ArgIdx:op.ArgIdx+1])
+			} else if op.ArgIdx < len(args) {
+				occurs = [][]string{args[op.ArgIdx : op.ArgIdx+1]}
 			}
 		} else if op.IsFlag() {
-			err = op.BindTo(v, tokens[idx])
+			occurs = tokens[idx]
+			if len(occurs) > 1 && !op.accumulates() {
+				// the last one overrides the others
+				occurs = occurs[len(occurs)-1:]
+			}
 		} else {
 			return args, fmt.Errorf("invalid option type: %v", op.Type)
 		}
+
+		var (
+			err  error
+			vals []string
+		)
+		from := ""
+		source := SourceFlag
+		if occurs == nil {
+			vals, source, from = h.lookupValue(st, op)
+			if from != "" && !canBeValues(op.Typer, vals) {
+				err = fmt.Errorf("unexpected value")
+			}
+			if vals != nil {
+				occurs = [][]string{vals}
+			}
+		}
+		provided := occurs != nil
+		if err == nil && !provided && op.HasDefault() {
+			err = op.BindTo(v, nil)
+		}
+		for _, occur := range occurs {
+			if err != nil {
+				break
+			}
+			vals = occur
+			err = op.BindTo(v, vals)
+		}
 		if err != nil {
+			err = op.valueError(vals, err)
+			if from != "" {
+				err = fmt.Errorf("%v: %w", from, err)
+			}
 			return nil, err
 		}
+		if op.Persistent && source != SourceFlag {
+			// it can be given to the sub handlers
+			st.unsetPersistent = append(st.unsetPersistent, unsetOption{op, provided})
+		} else if !provided && !op.IsOptional() {
+			missing = append(missing, op)
+		}
+		if provided {
+			h.warnDeprecated(op)
+		}
+		sources[idx] = source
+		raws[idx] = occurs
+		if _, max := op.Typer.NumArgs(); max == 1 && len(occurs) == 1 && len(occurs[0]) > 1 && op.IsFlag() {
+			// multiple values in env or config file
+			raws[idx] = make([][]string, len(occurs[0]))
+			for i, val := range occurs[0] {
+				raws[idx][i] = []string{val}
+			}
+		}
 	}
-	if IsImplementVerifier(v.Type()) {
+	// checked by checkRequired, the values are not verified without them
+	st.missing = append(st.missing, missing...)
+	if len(missing) == 0 && v.IsValid() && IsImplementVerifier(v.Type()) {
 		if err := v.Interface().(FlaglyVerifier).FlaglyVerify(); err != nil {
 			return args, Error(err.Error())
 		}
 	}
+	st.effective.add(h, v, h.Options, sources, raws)
+	for _, f := range inherited {
+		if err := h.bindInherited(st, stack, f); err != nil {
+			return args, err
+		}
+	}
 	return args, nil
 }
 
+// bindInherited writes the persistent flag into the option value of its
+// handler in the stack.
+func (h *Handler) bindInherited(st *runState, stack []reflect.Value, f inheritedFlag) error {
+	depth := f.op.handler.depth()
+	if depth >= len(stack) || !stack[depth].IsValid() {
+		return fmt.Errorf("flag %v is not allowed here", f.op.DisplayName())
+	}
+	if st.setPersistent(f.op) {
+		// don't accumulate onto the default, env or config file
+		field := stack[depth].Elem().Field(f.op.Index)
+		field.Set(reflect.Zero(field.Type()))
+	}
+	if err := f.op.BindTo(stack[depth], f.vals); err != nil {
+		return f.op.valueError(f.vals, err)
+	}
+	h.warnDeprecated(f.op)
+	st.effective.update(stack[depth], f.op, f.vals)
+	return nil
+}
+
 func (h *Handler) bindStackToStruct(stack []reflect.Value, value reflect.Value) {
 	if value.Kind() == reflect.Ptr {
 		value = value.Elem()
@@ -427,7 +936,7 @@ func (h *Handler) bindStackToStruct(stack []reflect.Value, value reflect.Value)
 		if tag.GetName() == flaglyParentName ||
 			tag.FlaglyHas("parent") {
 			for _, s := range stack {
-				if s.Type().String() == field.Type.String() {
+				if s.IsValid() && s.Type().String() == field.Type.String() {
 					value.Field(i).Set(s)
 				}
 			}
@@ -506,49 +1015,88 @@ func (h *Handler) GetCommands() []string {
 }
 
 func (h *Handler) getChildNames() (names []string) {
-	for _, n := range h.GetChildren() {
+	for _, n := range h.visibleChildren() {
 		names = append(names, n.Name)
 	}
 	return names
 }
 
-func (h *Handler) Run(stack *[]reflect.Value, args []string, context map[string]reflect.Value) (err error) {
+func (h *Handler) visibleChildren() []*Handler {
+	children := h.GetChildren()
+	ret := make([]*Handler, 0, len(children))
+	for _, ch := range children {
+		if !ch.Hidden {
+			ret = append(ret, ch)
+		}
+	}
+	return ret
+}
+
+// newOptionValue returns a pointer to a new option struct, it's invalid if
+// the handler has no option struct.
+func (h *Handler) newOptionValue() reflect.Value {
+	if h.OptionType == nil {
+		return reflect.Value{}
+	}
+	t := h.OptionType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t)
+}
+
+func (h *Handler) Run(stack *[]reflect.Value, args []string, context map[string]reflect.Value) error {
+	return h.run(h.setting.newRun(), stack, args, context)
+}
+
+func (h *Handler) run(st *runState, stack *[]reflect.Value, args []string, context map[string]reflect.Value) (err error) {
 	defer func() {
 		if e := IsShowUsage(err); e != nil {
 			err = e.Trace(h)
 		}
 	}()
+	h.warnDeprecatedCommand()
+	st.effective.enter(h)
 	runed := false
-	var value reflect.Value
-	if h.OptionType != nil {
-		t := h.OptionType
-		if t.Kind() == reflect.Ptr {
-			t = t.Elem()
-		}
-		value = reflect.New(t)
-		args, err = h.parseToStruct(value, args)
+	value := h.newOptionValue()
+	if value.IsValid() || len(h.Options) > 0 {
+		args, err = h.parseToStruct(st, value, args, *stack)
 		if err != nil {
 			return err
 		}
 	}
+	if value.IsValid() {
+		h.bindStackToStruct(*stack, value)
+	}
 	*stack = append(*stack, value)
 
 	if len(args) > 0 {
-		enter := h.findEnterFunc(h.OptionType)
+		var enter *reflect.Method
+		if h.OptionType != nil {
+			enter = h.findEnterFunc(h.OptionType)
+		}
 		if enter != nil {
 			args := make([]reflect.Value, 1)
 			args[0] = value
 			enter.Func.Call(args)
 		}
-		for _, ch := range h.GetChildren() {
-			if args[0] == ch.Name {
-				err = ch.Run(stack, args[1:], context)
-				runed = true
-				break
-			}
+		var ch *Handler
+		ch, err = h.findChild(args[0])
+		if err != nil {
+			return err
 		}
+		if ch != nil {
+			err = ch.run(st, stack, args[1:], context)
+			runed = true
+		}
+	}
+	if !runed && len(args) > 0 && len(h.GetChildren()) > 0 && !h.HasArgOptions() {
+		return h.unknownCommand(args[0])
 	}
 	if !runed {
+		if err = st.checkRequired(h); err != nil {
+			return err
+		}
 		err = h.Call(*stack, args, context)
 	}
 	return err
@@ -596,7 +1144,7 @@ func (h *Handler) usage(buf *bytes.Buffer, prefix string) error {
 
 	hasFlags := h.HasFlagOptions()
 	hasArgs := h.HasArgOptions()
-	children := h.GetChildren()
+	children := h.visibleChildren()
 	hasCommands := len(children) > 0
 
 	if h.Name != "" {
@@ -604,6 +1152,14 @@ func (h *Handler) usage(buf *bytes.Buffer, prefix string) error {
 		if hasFlags {
 			buf.WriteString(" [option]")
 		}
+		for _, op := range h.Options {
+			if op.IsFlag() && !op.IsOptional() && !op.Hidden {
+				buf.WriteString(" " + op.DisplayName())
+				if op.HasArgName() {
+					buf.WriteString(" <" + *op.ArgName + ">")
+				}
+			}
+		}
 		if hasCommands {
 			buf.WriteString(" <command>")
 		}
@@ -614,12 +1170,10 @@ func (h *Handler) usage(buf *bytes.Buffer, prefix string) error {
 			for _, op := range h.Options {
 				if op.IsArg() {
 					buf.WriteString(" ")
-					if op.HasDefault() {
-						buf.WriteString("[")
-					}
-					buf.WriteString("<" + op.Name + ">")
-					if op.HasDefault() {
-						buf.WriteString("]")
+					if op.IsOptional() {
+						buf.WriteString("[<" + op.Name + ">]")
+					} else {
+						buf.WriteString("<" + op.Name + ">")
 					}
 				}
 			}
@@ -635,6 +1189,9 @@ func (h *Handler) usage(buf *bytes.Buffer, prefix string) error {
 	if hasFlags {
 		buf.WriteString(h.usageOptions("options"))
 	}
+	if global := h.persistentOptions(); len(global) > 0 {
+		buf.WriteString(usageOptionList("global options", global))
+	}
 
 	if hasCommands {
 		buf.WriteString("\ncommands:\n")
@@ -648,15 +1205,47 @@ func (h *Handler) usage(buf *bytes.Buffer, prefix string) error {
 }
 
 func (h *Handler) usageOptions(name string) string {
+	return usageOptionList(name, h.Options)
+}
+
+func usageOptionList(name string, ops []*Option) string {
 	buf := bytes.NewBuffer(nil)
 	buf.WriteString("\n" + name + ":\n")
-	for _, op := range h.Options {
+	for _, op := range ops {
+		if op.Hidden {
+			continue
+		}
 		op.usage(buf)
 		buf.WriteString("\n")
 	}
 	return buf.String()
 }
 
+// localFlagOptions returns the flags which are not persistent
+func (h *Handler) localFlagOptions() []*Option {
+	var ret []*Option
+	for _, op := range h.Options {
+		if op.IsFlag() && !op.Persistent {
+			ret = append(ret, op)
+		}
+	}
+	return ret
+}
+
+// persistentOptions returns the persistent flags of the ancestors which are
+// accepted by h, the nearest first.
+func (h *Handler) persistentOptions() []*Option {
+	var ret []*Option
+	for p := h.Parent; p != nil; p = p.Parent {
+		for _, op := range p.Options {
+			if op.Persistent && h.findOption(op.Name) < 0 && h.findPersistent(op.Name) == op {
+				ret = append(ret, op)
+			}
+		}
+	}
+	return ret
+}
+
 func (h *Handler) Close() {
 	if h.onExit != nil {
 		h.onExit()
@@ -666,7 +1255,11 @@ func (h *Handler) Close() {
 	}
 }
 
-func (h *Handler) Bind(ptr reflect.Value, args []string) (err error) {
+func (h *Handler) Bind(ptr reflect.Value, args []string) error {
+	return h.bind(h.setting.newRun(), ptr, args)
+}
+
+func (h *Handler) bind(st *runState, ptr reflect.Value, args []string) (err error) {
 	if ptr.Kind() != reflect.Ptr {
 		return ErrMustAPtrToStruct
 	}
@@ -675,16 +1268,20 @@ func (h *Handler) Bind(ptr reflect.Value, args []string) (err error) {
 			err = e.Trace(h)
 		}
 	}()
+	st.effective.enter(h)
 	if h.OptionType != nil {
 		t := h.OptionType
 		if t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
 		value := reflect.New(t)
-		_, err = h.parseToStruct(value, args)
+		_, err = h.parseToStruct(st, value, args, nil)
 		if err != nil {
 			return err
 		}
+		if err = st.checkRequired(h); err != nil {
+			return err
+		}
 
 		ptr.Elem().Set(value.Elem())
 	}
@@ -0,0 +1,640 @@
+package flagly
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type longOptions struct {
+	Verbose  bool   `name:"v" long:"verbose"`
+	Template string `name:"t" long:"template"`
+	Progress bool   `long:""`
+}
+
+func TestLongOption(t *testing.T) {
+	for _, args := range [][]string{
+		{"-v", "-t", "dir", "-progress"},
+		{"--verbose", "--template", "dir", "--progress"},
+		{"-verbose", "--t", "dir", "--progress"},
+	} {
+		var opt longOptions
+		if err := BindByArgs(&opt, append([]string{"long"}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		if !opt.Verbose || opt.Template != "dir" || !opt.Progress {
+			t.Fatal("error", args, opt)
+		}
+	}
+
+	fset, err := Compile("long", &longOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := fset.Usage()
+	if !strings.Contains(usage, "-t, --template") ||
+		!strings.Contains(usage, "--progress") ||
+		!strings.Contains(usage, "-h, --help") {
+		t.Fatal("error", usage)
+	}
+}
+
+type inlineOptions struct {
+	Port    int      `name:"port"`
+	Verbose bool     `name:"v" default:"true"`
+	Tags    []string `name:"tag"`
+	Name    string   `type:"[0]"`
+}
+
+func TestInlineValue(t *testing.T) {
+	var opt inlineOptions
+	args := []string{"inline", "-port=8080", "-v=false", "-tag=a", "--", "-name"}
+	if err := BindByArgs(&opt, args); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 8080 || opt.Verbose || len(opt.Tags) != 1 ||
+		opt.Tags[0] != "a" || opt.Name != "-name" {
+		t.Fatal("error", opt)
+	}
+
+	err := BindByArgs(&opt, []string{"inline", "-port=abc"})
+	if err == nil || !strings.Contains(err.Error(), `"abc" for -port`) {
+		t.Fatal("error", err)
+	}
+	err = BindByArgs(&opt, []string{"inline", "-v=yes"})
+	if err == nil || !strings.Contains(err.Error(), `"yes" for -v`) {
+		t.Fatal("error", err)
+	}
+}
+
+type bundleOptions struct {
+	Extract  bool   `name:"x"`
+	Verbose  bool   `name:"v"`
+	File     string `name:"f"`
+	Progress bool   `name:"progress"`
+	Xv       bool   `name:"xv"`
+	Fx       bool   `name:"fx"`
+}
+
+func TestBundling(t *testing.T) {
+	fset, err := Compile("bundle", &bundleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.SetBundling(true)
+
+	var opt bundleOptions
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-xvf", "file.tar", "-progress"}); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.Extract || !opt.Verbose || opt.File != "file.tar" || !opt.Progress {
+		t.Fatal("error", opt)
+	}
+
+	opt = bundleOptions{}
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-vf=a.tar"}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Extract || !opt.Verbose || opt.File != "a.tar" {
+		t.Fatal("error", opt)
+	}
+
+	err = fset.Bind(reflect.ValueOf(&opt), []string{"-xv"})
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatal("error", err)
+	}
+	err = fset.Bind(reflect.ValueOf(&opt), []string{"-fv", "a.tar"})
+	if err == nil || !strings.Contains(err.Error(), "-f") {
+		t.Fatal("error", err)
+	}
+	// -f can't be bundled, but it's still ambiguous
+	err = fset.Bind(reflect.ValueOf(&opt), []string{"-fx"})
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatal("error", err)
+	}
+}
+
+type strictRoot struct {
+	Verbose bool         `name:"v"`
+	Sub     *strictChild `flagly:"handler"`
+}
+
+type strictChild struct {
+	Quiet bool `name:"q"`
+}
+
+func (strictChild) FlaglyHandle() error { return nil }
+
+func TestUnknownFlag(t *testing.T) {
+	fset, err := Compile("strict", &strictRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fset.Run([]string{"sub", "-verbsoe"})
+	var unknown *UnknownFlagError
+	if !errors.As(err, &unknown) {
+		t.Fatal("error", err)
+	}
+	if unknown.Name != "-verbsoe" || strings.Join(unknown.Path, " ") != "strict sub" {
+		t.Fatal("error", unknown)
+	}
+	if !strings.Contains(err.Error(), "usage: strict [strict option] sub") {
+		t.Fatal("error", err)
+	}
+
+	fset.SetStrict(false)
+	if err := fset.Run([]string{"sub", "-verbsoe"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStdinArg(t *testing.T) {
+	fset, err := Compile("", &intersRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt intersLs
+	cat := fset.GetHandler("cat")
+	if err := cat.Bind(reflect.ValueOf(&opt), []string{"-l", "-", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.Long || strings.Join(opt.Files, " ") != "- a" {
+		t.Fatal("error", opt)
+	}
+}
+
+type suggestRoot struct {
+	Clone *suggestLeaf `flagly:"handler"`
+	Init  *suggestLeaf `flagly:"handler"`
+}
+
+type suggestLeaf struct {
+	Verbose bool `name:"verbose"`
+}
+
+func (suggestLeaf) FlaglyHandle() error { return nil }
+
+func TestSuggest(t *testing.T) {
+	fset, err := Compile("git", &suggestRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fset.Run([]string{"clnoe"})
+	var cmd *UnknownCommandError
+	if !errors.As(err, &cmd) || len(cmd.Suggestions) != 1 || cmd.Suggestions[0] != "clone" {
+		t.Fatal("error", err)
+	}
+	if !strings.HasPrefix(err.Error(), "unknown command 'clnoe' for 'git', did you mean 'clone'?") {
+		t.Fatal("error", err)
+	}
+
+	err = fset.Run([]string{"clone", "--verbsoe"})
+	var flag *UnknownFlagError
+	if !errors.As(err, &flag) || len(flag.Suggestions) != 1 || flag.Suggestions[0] != "--verbose" {
+		t.Fatal("error", err)
+	}
+
+	fset.SetSuggestDistance(0)
+	err = fset.Run([]string{"clnoe"})
+	if !errors.As(err, &cmd) || len(cmd.Suggestions) != 0 {
+		t.Fatal("error", err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	if editDistance("clnoe", "clone") != 2 || editDistance("", "abc") != 3 ||
+		editDistance("kitten", "sitting") != 3 {
+		t.Fatal("error")
+	}
+}
+
+type requiredOptions struct {
+	Repo   string `name:"repo" required:"true"`
+	Branch string `name:"b" required:"true" default:"master"`
+	Src    string `type:"[0]" required:"true"`
+	Dir    string `type:"[1]"`
+	Acct   string `name:"acct" desc:"the account is required here"`
+	Tenant string `name:"tenant" required:"false"`
+}
+
+func TestRequired(t *testing.T) {
+	var opt requiredOptions
+	err := BindByArgs(&opt, []string{"required"})
+	var missing *MissingOptionsError
+	if !errors.As(err, &missing) || strings.Join(missing.Names, ",") != "-repo,<src>" {
+		t.Fatal("error", err)
+	}
+	if !strings.Contains(err.Error(), "usage: required [option] -repo [--] <src> [<dir>]") {
+		t.Fatal("error", err)
+	}
+	if err := BindByArgs(&opt, []string{"required", "-repo", "r", "s"}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Repo != "r" || opt.Branch != "master" || opt.Src != "s" {
+		t.Fatal("error", opt)
+	}
+	fset, err := Compile("required", &requiredOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the word in desc and required:"false" are not required
+	for _, line := range strings.Split(fset.Usage(), "\n") {
+		if (strings.Contains(line, "-acct") || strings.Contains(line, "-tenant")) &&
+			strings.Contains(line, "(required)") {
+			t.Fatal("error", line)
+		}
+	}
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-repo", "r", "s"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type envRoot struct {
+	Port  int       `name:"port" default:"80"`
+	Debug bool      `name:"d" env:"DEBUG_MODE"`
+	Clone *envClone `flagly:"handler"`
+}
+
+type envClone struct {
+	Template string `name:"t" long:"template"`
+}
+
+func (envClone) FlaglyHandle() error { return nil }
+
+func TestEnv(t *testing.T) {
+	fset, err := Compile("app", &envRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.SetEnvPrefix("APP")
+	if name := fset.GetHandler("clone").Options[0].EnvName(); name != "APP_CLONE_TEMPLATE" {
+		t.Fatal("error", name)
+	}
+	if !strings.Contains(fset.Usage(), "[$APP_PORT]") ||
+		!strings.Contains(fset.Usage(), "[$DEBUG_MODE]") {
+		t.Fatal("error", fset.Usage())
+	}
+
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("DEBUG_MODE", "true")
+	var opt envRoot
+	if err := fset.Bind(reflect.ValueOf(&opt), nil); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 8080 || !opt.Debug {
+		t.Fatal("error", opt)
+	}
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-port", "90"}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 90 {
+		t.Fatal("error", opt)
+	}
+
+	t.Setenv("APP_PORT", "abc")
+	err = fset.Bind(reflect.ValueOf(&opt), nil)
+	if err == nil || !strings.Contains(err.Error(), "$APP_PORT") {
+		t.Fatal("error", err)
+	}
+}
+
+type persistRoot struct {
+	Verbose bool          `name:"v" persistent:"true" desc:"verbose output"`
+	Dir     string        `name:"C"`
+	Quiet   bool          `name:"q" persistent:"false"`
+	Color   bool          `name:"color" desc:"persistent color"`
+	Remote  *persistMid   `flagly:"handler"`
+	Clone   *persistClone `flagly:"handler"`
+}
+
+type persistMid struct {
+	Add *persistClone `flagly:"handler"`
+}
+
+type persistClone struct {
+	Root  *persistRoot `flagly:"parent"`
+	Depth int          `name:"depth"`
+	Repo  string       `type:"[0]"`
+}
+
+var persistVerbose bool
+
+func (c *persistClone) FlaglyHandle() error {
+	persistVerbose = c.Root.Verbose
+	return nil
+}
+
+func TestPersistent(t *testing.T) {
+	fset, err := Compile("git", &persistRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-v", "clone", "repo"},
+		{"clone", "-v", "repo"},
+		{"clone", "-depth", "1", "-v", "repo"},
+		{"remote", "add", "-v"},
+		{"remote", "-v", "add"},
+	} {
+		persistVerbose = false
+		e, err := fset.RunEffective(args)
+		if err != nil || !persistVerbose {
+			t.Fatal("error", args, err)
+		}
+		if v := e.Map()["v"]; v.Source != SourceFlag || v.Value != true {
+			t.Fatal("error", args, v)
+		}
+	}
+
+	// not persistent
+	var unknown *UnknownFlagError
+	for _, name := range []string{"-C", "-q", "-color"} {
+		err = fset.Run([]string{"clone", name, "repo"})
+		if !errors.As(err, &unknown) {
+			t.Fatal("error", name, err)
+		}
+	}
+
+	usage := fset.GetHandler("clone").Usage("git")
+	if !strings.Contains(usage, "global options:\n    -v") {
+		t.Fatal("error", usage)
+	}
+	err = fset.Run([]string{"clone", "-h"})
+	if !strings.Contains(err.Error(), "global options:") ||
+		!strings.Contains(err.Error(), "git options:\n    -C") ||
+		strings.Contains(err.Error(), "git options:\n    -v") {
+		t.Fatal("error", err)
+	}
+
+	cands := fset.Completer().Complete([]string{"clone", "-"})
+	if !strings.Contains(strings.Join(cands, " "), "-v") {
+		t.Fatal("error", cands)
+	}
+}
+
+type persistRequiredRoot struct {
+	Verbose bool                 `name:"v" persistent:"true" required:"true"`
+	Tags    []string             `name:"tag" persistent:"true" default:"x" env:"PTAGS"`
+	Sub     *persistRequiredLeaf `flagly:"handler"`
+}
+
+type persistRequiredLeaf struct {
+	Root *persistRequiredRoot `flagly:"parent"`
+}
+
+var persistRequiredTags []string
+
+func (l *persistRequiredLeaf) FlaglyHandle() error {
+	persistRequiredTags = l.Root.Tags
+	return nil
+}
+
+func TestPersistentRequired(t *testing.T) {
+	fset, err := Compile("app", &persistRequiredRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-v", "sub"},
+		{"sub", "-v"},
+	} {
+		if err := fset.Run(args); err != nil {
+			t.Fatal("error", args, err)
+		}
+		if strings.Join(persistRequiredTags, " ") != "x" {
+			t.Fatal("error", args, persistRequiredTags)
+		}
+	}
+	var missing *MissingOptionsError
+	if err := fset.Run([]string{"sub"}); !errors.As(err, &missing) {
+		t.Fatal("error", err)
+	}
+
+	// the flag given to the sub command replaces the default
+	if err := fset.Run([]string{"-tag", "a", "sub", "-v", "-tag", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(persistRequiredTags, " ") != "a b" {
+		t.Fatal("error", persistRequiredTags)
+	}
+	if err := fset.Run([]string{"sub", "-v", "-tag", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(persistRequiredTags, " ") != "b" {
+		t.Fatal("error", persistRequiredTags)
+	}
+
+	// and replaces the value from env
+	t.Setenv("PTAGS", "env")
+	e, err := fset.RunEffective([]string{"sub", "-v", "-tag", "cli"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(persistRequiredTags, " ") != "cli" {
+		t.Fatal("error", persistRequiredTags)
+	}
+	if line := e.FlagLine(); line != "app -v -tag=cli sub" {
+		t.Fatal("error", line)
+	}
+	if err := fset.Run([]string{"sub", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(persistRequiredTags, " ") != "env" {
+		t.Fatal("error", persistRequiredTags)
+	}
+}
+
+type intersRoot struct {
+	Ls  *intersLs  `flagly:"handler,interspersed"`
+	Cat *intersLs  `flagly:"handler"`
+	Sub *intersSub `flagly:"handler"`
+}
+
+type intersLs struct {
+	Long  bool     `name:"l"`
+	Files []string `type:"[]"`
+}
+
+type intersSub struct {
+	Ls *intersLs `flagly:"handler"`
+}
+
+func TestInterspersed(t *testing.T) {
+	fset, err := Compile("", &intersRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt intersLs
+	ls := fset.GetHandler("ls")
+	if err := ls.Bind(reflect.ValueOf(&opt), []string{"a", "-l", "b", "--", "-c"}); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.Long || strings.Join(opt.Files, " ") != "a b -c" {
+		t.Fatal("error", opt)
+	}
+	opt = intersLs{}
+	if err := ls.Bind(reflect.ValueOf(&opt), []string{"a", "-", "-l", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.Long || strings.Join(opt.Files, " ") != "a - b" {
+		t.Fatal("error", opt)
+	}
+
+	opt = intersLs{}
+	cat := fset.GetHandler("cat")
+	if err := cat.Bind(reflect.ValueOf(&opt), []string{"a", "-l", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Long || strings.Join(opt.Files, " ") != "a -l b" {
+		t.Fatal("error", opt)
+	}
+
+	fset.SetInterspersed(true)
+	opt = intersLs{}
+	if err := cat.Bind(reflect.ValueOf(&opt), []string{"a", "-l", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.Long || strings.Join(opt.Files, " ") != "a b" {
+		t.Fatal("error", opt)
+	}
+
+	// the sub commands are still dispatched
+	e, err := fset.RunEffective([]string{"sub", "ls", "a", "-l"})
+	if IsShowUsage(err) == nil {
+		t.Fatal("error", err)
+	}
+	if e.Map()["sub.ls.l"].Value != true {
+		t.Fatal("error", e)
+	}
+}
+
+type aliasRoot struct {
+	Clone    *aliasCmd `flagly:"handler" alias:"cl"`
+	Checkout *aliasCmd `flagly:"handler" alias:"co,switch"`
+	Commit   *aliasCmd `flagly:"handler"`
+	Status   *aliasCmd `flagly:"handler"`
+}
+
+type aliasCmd struct{}
+
+var aliasRan string
+
+func (aliasCmd) FlaglyHandle(h *Handler) error {
+	aliasRan = h.Name
+	return nil
+}
+
+func TestAlias(t *testing.T) {
+	fset, err := Compile("git", &aliasRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for args, name := range map[string]string{
+		"co":     "checkout",
+		"switch": "checkout",
+		"cl":     "clone",
+		"commit": "commit",
+	} {
+		aliasRan = ""
+		if err := fset.Run([]string{args}); err != nil || aliasRan != name {
+			t.Fatal("error", args, err, aliasRan)
+		}
+	}
+	var unknown *UnknownCommandError
+	if err := fset.Run([]string{"st"}); !errors.As(err, &unknown) {
+		t.Fatal("error", err)
+	}
+	// each command is suggested once by its name
+	err = fset.Run([]string{"cle"})
+	if !errors.As(err, &unknown) || strings.Join(unknown.Suggestions, " ") != "clone checkout" {
+		t.Fatal("error", err)
+	}
+
+	fset.SetPrefixMatching(true)
+	aliasRan = ""
+	if err := fset.Run([]string{"st"}); err != nil || aliasRan != "status" {
+		t.Fatal("error", err)
+	}
+	aliasRan = ""
+	if err := fset.Run([]string{"sw"}); err != nil || aliasRan != "checkout" {
+		t.Fatal("error", err)
+	}
+	err = fset.Run([]string{"c"})
+	var ambiguous *AmbiguousCommandError
+	if !errors.As(err, &ambiguous) ||
+		strings.Join(ambiguous.Candidates, " ") != "clone checkout commit" ||
+		!strings.Contains(err.Error(), "could be 'clone', 'checkout', 'commit'") {
+		t.Fatal("error", err)
+	}
+
+	if !strings.Contains(fset.Usage(), "checkout, co, switch") {
+		t.Fatal("error", fset.Usage())
+	}
+	cands := fset.Completer().Complete([]string{"c"})
+	if strings.Join(cands, " ") != "clone cl checkout co commit" {
+		t.Fatal("error", cands)
+	}
+}
+
+type deprecatedRoot struct {
+	Verbose bool           `name:"v"`
+	Debug   bool           `name:"debug" hidden:"true"`
+	Quiet   bool           `name:"q" deprecated:"use -v=false instead"`
+	All     bool           `name:"a" desc:"show hidden files"`
+	Shown   bool           `name:"shown" hidden:"false"`
+	Old     *deprecatedCmd `flagly:"handler" deprecated:"use new instead"`
+	Secret  *deprecatedCmd `flagly:"handler" hidden:"true"`
+	New     *deprecatedCmd `flagly:"handler"`
+}
+
+type deprecatedCmd struct{}
+
+func (deprecatedCmd) FlaglyHandle() error { return nil }
+
+func TestHiddenDeprecated(t *testing.T) {
+	fset, err := Compile("app", &deprecatedRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warn := bytes.NewBuffer(nil)
+	fset.SetWarningOutput(warn)
+
+	usage := fset.Usage()
+	if strings.Contains(usage, "debug") || strings.Contains(usage, "secret") ||
+		!strings.Contains(usage, "show hidden files") || !strings.Contains(usage, "-shown") ||
+		!strings.Contains(usage, "-q                  (deprecated)") ||
+		!strings.Contains(usage, "old                 (deprecated)") {
+		t.Fatal("error", usage)
+	}
+	cands := fset.Completer().Complete([]string{""})
+	if strings.Contains(strings.Join(cands, " "), "secret") {
+		t.Fatal("error", cands)
+	}
+	cands = fset.Completer().Complete([]string{"-"})
+	if strings.Contains(strings.Join(cands, " "), "debug") {
+		t.Fatal("error", cands)
+	}
+
+	if err := fset.Run([]string{"-debug", "secret"}); err != nil || warn.Len() != 0 {
+		t.Fatal("error", err, warn.String())
+	}
+	if err := fset.Run([]string{"-q", "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if warn.String() != "warning: flag -q is deprecated, use -v=false instead\n"+
+		"warning: command 'app old' is deprecated, use new instead\n" {
+		t.Fatal("error", warn.String())
+	}
+
+	h := fset.GetHandler("secret")
+	if !h.Hidden || fset.GetHandler("old").Deprecated != "use new instead" {
+		t.Fatal("error", h)
+	}
+	for _, op := range fset.Handler().Options {
+		if op.Name == "debug" && !op.Hidden {
+			t.Fatal("error", op)
+		}
+	}
+}
@@ -3,12 +3,13 @@ package flagly
 import "reflect"
 
 var (
-	emptyFlaglyIniter   = reflect.TypeOf(new(FlaglyIniter)).Elem()
-	emptyFlaglyDescer   = reflect.TypeOf(new(FlaglyDescer)).Elem()
-	emptyFlaglyVerifier = reflect.TypeOf(new(FlaglyVerifier)).Elem()
-	FlaglyIniterName    = "FlaglyInit"
-	flaglyHandle        = "FlaglyHandle"
-	flaglyEnter         = "FlaglyEnter"
+	emptyFlaglyIniter    = reflect.TypeOf(new(FlaglyIniter)).Elem()
+	emptyFlaglyDescer    = reflect.TypeOf(new(FlaglyDescer)).Elem()
+	emptyFlaglyVerifier  = reflect.TypeOf(new(FlaglyVerifier)).Elem()
+	emptyFlaglyCompleter = reflect.TypeOf(new(FlaglyCompleter)).Elem()
+	FlaglyIniterName     = "FlaglyInit"
+	flaglyHandle         = "FlaglyHandle"
+	flaglyEnter          = "FlaglyEnter"
 )
 
 type FlaglyIniter interface {
@@ -23,6 +24,12 @@ type FlaglyVerifier interface {
 	FlaglyVerify() error
 }
 
+// FlaglyCompleter is implemented by an option struct to complete the values
+// of its options, the receiver is filled with the options typed so far.
+type FlaglyCompleter interface {
+	FlaglyComplete(op *Option, prefix string) []Candidate
+}
+
 func IsImplementIniter(t reflect.Type) bool {
 	return IsImplemented(t, emptyFlaglyIniter)
 }
@@ -35,6 +42,10 @@ func IsImplementVerifier(t reflect.Type) bool {
 	return IsImplemented(t, emptyFlaglyVerifier)
 }
 
+func IsImplementCompleter(t reflect.Type) bool {
+	return IsImplemented(t, emptyFlaglyCompleter)
+}
+
 func IsImplemented(t, target reflect.Type) bool {
 	if t.Implements(target) {
 		return true
@@ -0,0 +1,343 @@
+package flagly
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/google/shlex"
+)
+
+// InteractiveConfig configures the shell started by FlaglySet.Interactive
+type InteractiveConfig struct {
+	// default is "> "
+	Prompt      string
+	HistoryFile string
+
+	// default are os.Stdin, os.Stdout and os.Stderr, the shell works
+	// without a terminal if Stdin is given.
+	Stdin  io.ReadCloser
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (cfg *InteractiveConfig) init() {
+	if cfg.Prompt == "" {
+		cfg.Prompt = "> "
+	}
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = os.Stderr
+	}
+}
+
+// shellScope is the handler which the shell is currently in, moved by
+// `use` or `cd`, the commands are run relative to it.
+type shellScope struct {
+	// from the root to the current one
+	handlers []*Handler
+	// the options of the handlers parsed on entry of each level, the
+	// persistent flags given to a level don't change the upper ones.
+	stacks [][]reflect.Value
+	// the persistent options which are not given on entry of each level
+	unset [][]unsetOption
+}
+
+// newShellScope returns the scope of the root, the error is of the values
+// from the defaults, env or config file.
+func newShellScope(root *Handler) (*shellScope, error) {
+	st := root.setting.newRun()
+	value := root.newOptionValue()
+	if value.IsValid() {
+		// apply the defaults
+		if _, err := root.parseToStruct(st, value, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &shellScope{
+		handlers: []*Handler{root},
+		stacks:   [][]reflect.Value{{value}},
+		unset:    [][]unsetOption{st.unsetPersistent},
+	}, nil
+}
+
+func (s *shellScope) current() *Handler {
+	return s.handlers[len(s.handlers)-1]
+}
+
+// Path returns the names of the handlers from the root, without the root
+func (s *shellScope) path() []string {
+	return s.current().subPath()
+}
+
+// enter moves the scope by the words like `level1 -v level2`, `..` or `/`,
+// the scope is unchanged if any of them fails.
+func (s *shellScope) enter(words []string) error {
+	handlers := append([]*Handler{}, s.handlers...)
+	stacks := append([][]reflect.Value{}, s.stacks...)
+	unset := append([][]unsetOption{}, s.unset...)
+	for len(words) > 0 {
+		name := words[0]
+		words = words[1:]
+		switch name {
+		case "..":
+			if len(handlers) > 1 {
+				handlers = handlers[:len(handlers)-1]
+				stacks = stacks[:len(stacks)-1]
+				unset = unset[:len(unset)-1]
+			}
+			continue
+		case "/":
+			handlers, stacks, unset = handlers[:1], stacks[:1], unset[:1]
+			continue
+		}
+		cur := handlers[len(handlers)-1]
+		ch, err := cur.findChild(name)
+		if err != nil {
+			return err
+		}
+		if ch == nil {
+			return cur.unknownCommand(name)
+		}
+		if len(ch.GetChildren()) == 0 {
+			return fmt.Errorf("'%v' has no sub commands", name)
+		}
+		ch.warnDeprecatedCommand()
+		stack := copyStack(handlers, stacks[len(stacks)-1])
+		st := ch.setting.newRun()
+		st.unsetPersistent = append([]unsetOption{}, unset[len(unset)-1]...)
+		value := ch.newOptionValue()
+		if value.IsValid() || len(ch.Options) > 0 {
+			words, err = ch.parseToStruct(st, value, words, stack)
+			if err == nil {
+				// the scope is entered, not dispatched
+				err = missingError(ch, st.missing)
+			}
+			if err != nil {
+				if e := IsShowUsage(err); e != nil {
+					err = traceScope(e.Trace(ch), handlers)
+				}
+				return err
+			}
+		}
+		if value.IsValid() {
+			ch.bindStackToStruct(stack, value)
+		}
+		handlers = append(handlers, ch)
+		stacks = append(stacks, append(stack, value))
+		unset = append(unset, st.unsetPersistent)
+	}
+	s.handlers, s.stacks, s.unset = handlers, stacks, unset
+	return nil
+}
+
+// run runs the command relative to the scope, the options parsed on entry
+// are kept for the sub commands.
+func (s *shellScope) run(f *FlaglySet, st *runState, args []string) error {
+	if len(s.handlers) == 1 {
+		return f.run(st, args, nil)
+	}
+	cur := s.current()
+	var ch *Handler
+	if len(args) > 0 {
+		// the error is returned by cur.Run below
+		ch, _ = cur.findChild(args[0])
+	}
+	var err error
+	if ch != nil {
+		enterScope(st, s.handlers)
+		st.unsetPersistent = append([]unsetOption{}, s.unset[len(s.unset)-1]...)
+		stack := copyStack(s.handlers, s.stacks[len(s.stacks)-1])
+		err = ch.run(st, &stack, args[1:], nil)
+		if e := IsShowUsage(err); e != nil {
+			err = traceScope(e, s.handlers)
+		}
+	} else {
+		enterScope(st, s.handlers[:len(s.handlers)-1])
+		st.unsetPersistent = append([]unsetOption{}, s.unset[len(s.unset)-2]...)
+		stack := copyStack(s.handlers, s.stacks[len(s.stacks)-1])
+		stack = stack[:len(stack)-1]
+		err = cur.run(st, &stack, args, nil)
+		if e := IsShowUsage(err); e != nil {
+			err = traceScope(e, s.handlers[:len(s.handlers)-1])
+		}
+	}
+	return err
+}
+
+// copyStack copies the option structs of the handlers, so the persistent
+// flags given to a line don't leak into the next ones.
+func copyStack(handlers []*Handler, stack []reflect.Value) []reflect.Value {
+	ret := make([]reflect.Value, len(stack))
+	for idx, v := range stack {
+		if !v.IsValid() {
+			continue
+		}
+		ret[idx] = reflect.New(v.Elem().Type())
+		ret[idx].Elem().Set(v.Elem())
+		// point to the copied parents
+		handlers[idx].bindStackToStruct(ret[:idx], ret[idx])
+	}
+	return ret
+}
+
+// enterScope records the handlers of the scope, which Effective.Path
+// starts with
+func enterScope(st *runState, handlers []*Handler) {
+	for _, h := range handlers {
+		st.effective.enter(h)
+	}
+}
+
+// traceScope appends the handlers of the scope to the usage trace
+func traceScope(e *showUsageError, handlers []*Handler) error {
+	for i := len(handlers) - 1; i >= 0; i-- {
+		e = e.Trace(handlers[i])
+	}
+	return e
+}
+
+type scopeCompleter struct {
+	scope *shellScope
+}
+
+func (c *scopeCompleter) DoSegment(seg [][]rune, n int) [][]rune {
+	if len(seg) > 1 {
+		switch string(seg[0]) {
+		case "use", "cd":
+			return (&HandlerCompleter{c.scope.current()}).DoSegment(seg[1:], n)
+		case "help":
+			seg = seg[1:]
+		}
+	}
+	return (&HandlerCompleter{c.scope.current()}).DoSegment(seg, n)
+}
+
+// Interactive runs a shell over the handler tree until `exit`, `quit` or
+// EOF. Each line is split like a shell command and run by the FlaglySet,
+// the errors and the panics of the handlers are printed instead of ending
+// the shell. It doesn't start if the root options can't be applied.
+//
+// Builtin commands:
+//
+//	use|cd <command>...  move into the command, `..` moves back
+//	..                   move back to the parent command
+//	help [command...]    show the usage if the tree has no help command
+//
+// The options given to `use` are kept for the commands run in it.
+func (f *FlaglySet) Interactive(cfg *InteractiveConfig) error {
+	if cfg == nil {
+		cfg = &InteractiveConfig{}
+	}
+	cfg.init()
+	scope, err := newShellScope(f.subHandler)
+	if err != nil {
+		return err
+	}
+	rlCfg := &readline.Config{
+		Prompt:       cfg.Prompt,
+		HistoryFile:  cfg.HistoryFile,
+		AutoComplete: readline.SegmentAutoComplete(&scopeCompleter{scope}),
+		Stdin:        cfg.Stdin,
+		Stdout:       cfg.Stdout,
+		Stderr:       cfg.Stderr,
+	}
+	if cfg.Stdin != nil {
+		rlCfg.FuncIsTerminal = func() bool { return false }
+	}
+	rl, err := readline.NewEx(rlCfg)
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+
+	output := f.subHandler.setting.output
+	f.SetOutput(cfg.Stdout)
+	defer f.SetOutput(output)
+
+	for {
+		rl.SetPrompt(scopePrompt(scope, cfg.Prompt))
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			if line == "" {
+				return nil
+			}
+			continue
+		} else if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		if err := f.runLine(scope, f.subHandler.setting.newRun(), line); err != nil {
+			fmt.Fprintln(cfg.Stderr, strings.TrimRight(err.Error(), "\n"))
+		}
+	}
+}
+
+func scopePrompt(scope *shellScope, prompt string) string {
+	path := scope.path()
+	if len(path) == 0 {
+		return prompt
+	}
+	return strings.Join(path, "/") + prompt
+}
+
+// runLine runs a line of the shell
+func (f *FlaglySet) runLine(scope *shellScope, st *runState, line string) (err error) {
+	args, err := shlex.Split(line)
+	if err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	cur := scope.current()
+	switch {
+	case args[0] == "..":
+		return scope.enter(args)
+	case (args[0] == "use" || args[0] == "cd") && cur.GetHandler(args[0]) == nil:
+		return scope.enter(args[1:])
+	case args[0] == "help" && cur.GetHandler("help") == nil:
+		return scope.help(args[1:])
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+	return scope.run(f, st, args)
+}
+
+// help returns the usage of the handler of path as an error
+func (s *shellScope) help(path []string) error {
+	hs := make([]*Handler, 0, len(s.handlers)+len(path))
+	for i := len(s.handlers) - 1; i >= 0; i-- {
+		hs = append(hs, s.handlers[i])
+	}
+	for _, name := range path {
+		h, err := hs[0].findChild(name)
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			return hs[0].unknownCommand(name)
+		}
+		hs = append([]*Handler{h}, hs...)
+	}
+	return errors.New(ShowUsage(hs))
+}
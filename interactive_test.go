@@ -0,0 +1,130 @@
+package flagly
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+type replRoot struct {
+	Echo  *replEcho  `flagly:"handler"`
+	Panic *replPanic `flagly:"handler"`
+}
+
+type replEcho struct {
+	Upper bool     `name:"u"`
+	Words []string `type:"[]"`
+}
+
+func (e *replEcho) FlaglyHandle(h *Handler) error {
+	line := strings.Join(e.Words, " ")
+	if e.Upper {
+		line = strings.ToUpper(line)
+	}
+	_, err := io.WriteString(h.setting.output, line+"\n")
+	return err
+}
+
+type replPanic struct{}
+
+func (replPanic) FlaglyHandle() error { panic("boom") }
+
+func TestInteractive(t *testing.T) {
+	fset, err := Compile("", &replRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	err = fset.Interactive(&InteractiveConfig{
+		Stdin: io.NopCloser(strings.NewReader(
+			"echo hello 'big world'\n" +
+				"panic\n" +
+				"ehco\n" +
+				"help echo\n" +
+				"echo -u still alive\n" +
+				"quit\n" +
+				"echo unreachable\n")),
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, errOut := stdout.String(), stderr.String()
+	if !strings.Contains(out, "hello big world\n") ||
+		!strings.Contains(out, "STILL ALIVE\n") ||
+		strings.Contains(out, "unreachable") {
+		t.Fatal("error", out)
+	}
+	if !strings.Contains(errOut, "panic: boom") ||
+		!strings.Contains(errOut, "did you mean 'echo'?") ||
+		!strings.Contains(errOut, "usage: echo [option]") {
+		t.Fatal("error", errOut)
+	}
+}
+
+type scopeRoot struct {
+	Remote *scopeRemote `flagly:"handler"`
+}
+
+type scopeRemote struct {
+	Name string     `name:"name"`
+	Show *scopeShow `flagly:"handler"`
+}
+
+type scopeShow struct {
+	Remote *scopeRemote `flagly:"parent"`
+}
+
+func (s *scopeShow) FlaglyHandle(h *Handler) error {
+	_, err := io.WriteString(h.setting.output, "remote="+s.Remote.Name+"\n")
+	return err
+}
+
+func TestInteractiveScope(t *testing.T) {
+	fset, err := Compile("", &scopeRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	err = fset.Interactive(&InteractiveConfig{
+		Stdin: io.NopCloser(strings.NewReader(
+			"use remote -name origin\n" +
+				"show\n" +
+				"use show\n" +
+				"help\n" +
+				"..\n" +
+				"remote -name up show\n")),
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, errOut := stdout.String(), stderr.String()
+	if !strings.Contains(out, "remote=origin\n") ||
+		!strings.Contains(out, "remote=up\n") {
+		t.Fatal("error", out)
+	}
+	if !strings.Contains(errOut, "'show' has no sub commands") ||
+		!strings.Contains(errOut, "usage: remote [option]") {
+		t.Fatal("error", errOut)
+	}
+
+	scope, err := newShellScope(fset.subHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.enter([]string{"remote", "nope"}); err == nil {
+		t.Fatal("error")
+	}
+	if len(scope.handlers) != 1 {
+		t.Fatal("error", scope.path())
+	}
+	if scope.enter([]string{"remote"}) != nil || scopePrompt(scope, "> ") != "remote> " {
+		t.Fatal("error", scope.path())
+	}
+}
@@ -42,8 +42,25 @@ type Option struct {
 	Default   *string
 	ArgName   *string
 	ArgIdx    int
+	Required  bool
+	Secret    bool
 	ShowUsage bool
-	Tag       StructTag
+	// the environment variable used when the flag is not given
+	Env string
+	// the flag is also accepted by the sub handlers at any depth
+	Persistent bool
+	// hidden options still parse but are not listed in usage and completion
+	Hidden bool
+	// the message of the warning printed when the option is used
+	Deprecated string
+	Tag        StructTag
+
+	handler *Handler
+	// the value of the flag is a path of config file
+	loadConfig bool
+	// String() of the value set by FlaglyInit, it's shown as the default
+	// in the usage if it's not the zero value.
+	initString string
 }
 
 func NewHelpFlag() *Option {
@@ -52,24 +69,64 @@ func NewHelpFlag() *Option {
 		panic(err)
 	}
 	op.ShowUsage = true
+	op.LongName = "help"
 	op.Desc = "show help"
 	return op
 }
 
+func NewConfigFlag(name string) *Option {
+	op, err := NewFlag(name, reflect.TypeOf(""))
+	if err != nil {
+		panic(err)
+	}
+	op.loadConfig = true
+	op.ArgName = &[]string{"path"}[0]
+	op.Desc = "load options from config file"
+	return op
+}
+
+// NewFlag returns a flag whose Typer is from the default registry
 func NewFlag(name string, bind reflect.Type) (*Option, error) {
+	return newFlag(defaultTypes, name, bind)
+}
+
+func newFlag(types *TypeRegistry, name string, bind reflect.Type) (*Option, error) {
 	op := &Option{
 		Index:    -1,
 		Name:     name,
 		BindType: bind,
 		Type:     FlagOption,
 	}
-	if err := op.init(); err != nil {
+	if err := op.init(types); err != nil {
 		return nil, err
 	}
 	return op, nil
 }
 
+// NewCountFlag returns a flag which counts how many times it's given,
+// bind must be an integer type.
+func NewCountFlag(name string, bind reflect.Type) (*Option, error) {
+	switch elemType(bind).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, fmt.Errorf("count flag %v must be an integer: %v", name, bind)
+	}
+	return &Option{
+		Index:    -1,
+		Name:     name,
+		BindType: bind,
+		Type:     FlagOption,
+		Typer:    Count(0),
+	}, nil
+}
+
+// NewArg returns an argument whose Typer is from the default registry
 func NewArg(name string, idx int, bind reflect.Type) (*Option, error) {
+	return newArg(defaultTypes, name, idx, bind)
+}
+
+func newArg(types *TypeRegistry, name string, idx int, bind reflect.Type) (*Option, error) {
 	op := &Option{
 		Index:    -1,
 		Name:     name,
@@ -77,14 +134,14 @@ func NewArg(name string, idx int, bind reflect.Type) (*Option, error) {
 		BindType: bind,
 		ArgIdx:   idx,
 	}
-	if err := op.init(); err != nil {
+	if err := op.init(types); err != nil {
 		return nil, err
 	}
 	return op, nil
 }
 
-func (o *Option) init() error {
-	typer, err := GetTyper(o.BindType)
+func (o *Option) init(types *TypeRegistry) error {
+	typer, err := types.GetTyper(o.BindType)
 	if err != nil {
 		return err
 	}
@@ -92,6 +149,35 @@ func (o *Option) init() error {
 	return nil
 }
 
+// retype resolves the Typer again from types, e.g. the registry of the tree
+// which the handler is added to. The arg name follows the Typer unless it's
+// changed.
+func (o *Option) retype(types *TypeRegistry) error {
+	if o.BindType == nil || o.Tag.Bool("count") {
+		return nil
+	}
+	old := typerArgName(o.Typer)
+	if err := o.init(types); err != nil {
+		return err
+	}
+	if o.ArgName == nil && old == "" || o.ArgName != nil && *o.ArgName == old {
+		if argName := typerArgName(o.Typer); argName != "" {
+			o.ArgName = &argName
+		} else {
+			o.ArgName = nil
+		}
+	}
+	return nil
+}
+
+// typerArgName returns the ArgName() of t, it's empty if t has no name
+func typerArgName(t Typer) string {
+	if namer, ok := t.(BaseTypeArgNamer); ok {
+		return namer.ArgName()
+	}
+	return ""
+}
+
 func (o *Option) GetTree(lambdaMap map[string]func() []string) []Tree {
 	var candidates []string
 	if selectCall := o.Tag.Get("selectCall"); selectCall != "" {
@@ -116,18 +202,122 @@ func (o *Option) BindTo(value reflect.Value, args []string) error {
 	f := value.Elem().Field(o.Index)
 	if args == nil {
 		if o.HasDefault() {
-			return o.Typer.Set(f, []string{*o.Default})
+			return o.Typer.Set(f, o.splitValues([]string{*o.Default}))
 		}
 	} else {
-		return o.Typer.Set(f, args)
+		return o.Typer.Set(f, o.splitValues(args))
 	}
 	return nil
 }
 
+// splitValues splits each of the values by the `sep` tag, e.g.
+// `-tag a,b` is the same as `-tag a -tag b` with `sep:","`.
+func (o *Option) splitValues(args []string) []string {
+	sep := o.Tag.Get("sep")
+	if sep == "" {
+		return args
+	}
+	ret := make([]string, 0, len(args))
+	for _, arg := range args {
+		ret = append(ret, strings.Split(arg, sep)...)
+	}
+	return ret
+}
+
+// accumulates reports whether every occurrence of the flag is bound,
+// otherwise only the last one is.
+func (o *Option) accumulates() bool {
+	a, ok := o.Typer.(BaseTypeAccumulator)
+	return ok && a.Accumulate()
+}
+
+// usageDefault returns the default value shown in the usage, it's the
+// `default` tag or String() of the value set by FlaglyInit.
+func (o *Option) usageDefault() (string, bool) {
+	if o.HasDefault() {
+		return *o.Default, true
+	}
+	if o.initString != "" {
+		return o.initString, true
+	}
+	return "", false
+}
+
 func (o *Option) HasArgName() bool {
 	return o.ArgName != nil
 }
 
+func (o *Option) HasLongName() bool {
+	return o.LongName != ""
+}
+
+// FlagNames returns the names of the flag as typed in the command line,
+// e.g. `-t, --template`
+func (o *Option) FlagNames() string {
+	if !o.HasLongName() {
+		return "-" + o.Name
+	}
+	if o.LongName == o.Name {
+		return "--" + o.LongName
+	}
+	return "-" + o.Name + ", --" + o.LongName
+}
+
+// DisplayName returns the option as it is referred to in messages,
+// `-name` for flags and `<name>` for args
+func (o *Option) DisplayName() string {
+	if o.IsArg() {
+		return "<" + o.Name + ">"
+	}
+	if o.HasLongName() && len(o.Name) > 1 {
+		return "--" + o.LongName
+	}
+	return "-" + o.Name
+}
+
+func (o *Option) valueError(args []string, err error) error {
+	if args == nil && o.HasDefault() {
+		args = []string{*o.Default}
+	}
+	return fmt.Errorf("invalid value %q for %v: %w",
+		strings.Join(args, " "), o.DisplayName(), err)
+}
+
+// EnvName returns the environment variable of the flag, it's the `env` tag
+// or derived from the env prefix of the FlaglySet, e.g. APP_CLONE_TEMPLATE
+func (o *Option) EnvName() string {
+	if o.Env != "" {
+		return o.Env
+	}
+	if !o.IsFlag() || o.ShowUsage || o.handler == nil {
+		return ""
+	}
+	prefix := o.handler.setting.envPrefix
+	if prefix == "" {
+		return ""
+	}
+	name := o.Name
+	if o.HasLongName() {
+		name = o.LongName
+	}
+	parts := append([]string{prefix}, o.handler.subPath()...)
+	parts = append(parts, name)
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.Join(parts, "_"))
+}
+
+// IsOptional reports whether the option can be omitted in command line
+func (o *Option) IsOptional() bool {
+	return !o.Required || o.HasDefault()
+}
+
 func (o *Option) HasDefault() bool {
 	return o.Default != nil
 }
@@ -149,18 +339,19 @@ func (o *Option) usage(buf *bytes.Buffer) {
 	b.WriteString("    ")
 	length := 4 + 20
 	if o.IsFlag() {
-		b.WriteString("-" + o.Name)
+		b.WriteString(o.FlagNames())
 		min, _ := o.Typer.NumArgs()
 
 		if min > 0 {
+			def, hasDefault := o.usageDefault()
 			if o.HasArgName() {
-				if o.HasDefault() {
-					b.WriteString(fmt.Sprintf(" <%v=%v>", *o.ArgName, *o.Default))
+				if hasDefault {
+					b.WriteString(fmt.Sprintf(" <%v=%v>", *o.ArgName, def))
 				} else {
 					b.WriteString(fmt.Sprintf(" <%v>", *o.ArgName))
 				}
-			} else if o.HasDefault() {
-				b.WriteString(fmt.Sprintf(` "%v"`, *o.Default))
+			} else if hasDefault {
+				b.WriteString(fmt.Sprintf(` "%v"`, def))
 			} else {
 
 			}
@@ -172,13 +363,23 @@ func (o *Option) usage(buf *bytes.Buffer) {
 		}
 	}
 
-	if o.HasDesc() {
+	desc := o.Desc
+	if o.Required {
+		desc = strings.TrimSpace(desc + " (required)")
+	}
+	if env := o.EnvName(); env != "" {
+		desc = strings.TrimSpace(desc + " [$" + env + "]")
+	}
+	if o.Deprecated != "" {
+		desc = strings.TrimSpace(desc + " (deprecated)")
+	}
+	if desc != "" {
 		if b.Len() > length {
 			b.WriteString("\n" + strings.Repeat(" ", length))
 		} else {
 			b.WriteString(strings.Repeat(" ", length-b.Len()))
 		}
-		b.WriteString(o.Desc)
+		b.WriteString(desc)
 	}
 
 	b.WriteTo(buf)
@@ -253,9 +454,11 @@ func ParseStructToOptions(h *Handler, t reflect.Type) (ret []*Option, err error)
 		var op *Option
 
 		if IsWrapBy(tag.Get("type"), "[]") {
-			op, err = NewArg(name, GetIdxInArray(tag.Get("type")), field.Type)
+			op, err = newArg(h.setting.types, name, GetIdxInArray(tag.Get("type")), field.Type)
+		} else if tag.Bool("count") {
+			op, err = NewCountFlag(name, field.Type)
 		} else {
-			op, err = NewFlag(name, field.Type)
+			op, err = newFlag(h.setting.types, name, field.Type)
 		}
 		if err != nil {
 			return nil, err
@@ -265,9 +468,34 @@ func ParseStructToOptions(h *Handler, t reflect.Type) (ret []*Option, err error)
 		if op.Name == "-" {
 			return nil, fmt.Errorf(`name "-" is not allowed`)
 		}
+		if init, ok := stringOf(value.Elem().Field(i)); ok {
+			// like the flag package, the zero value is not a default
+			if zero, _ := stringOf(newValue(field.Type)); init != zero {
+				op.initString = init
+			}
+		}
+		if tag.Get("sep") != "" && !op.accumulates() {
+			return nil, fmt.Errorf("sep of %v needs a slice or a map: %v", name, field.Type)
+		}
 		op.Index = i
+		op.handler = h
+
+		if long := tag.GetPtr("long"); long != nil && op.IsFlag() {
+			op.LongName = *long
+			if op.LongName == "" {
+				op.LongName = strings.ToLower(field.Name)
+			}
+		}
 
 		op.Default = tag.GetPtr("default")
+		op.Required = tag.Bool("required")
+		op.Secret = tag.Bool("secret")
+		op.Hidden = tag.Bool("hidden")
+		op.Deprecated = tag.Get("deprecated")
+		if op.IsFlag() {
+			op.Env = tag.Get("env")
+			op.Persistent = tag.Bool("persistent")
+		}
 		if namer, ok := op.Typer.(BaseTypeArgNamer); ok {
 			argName := namer.ArgName()
 			if argName != "" {
@@ -0,0 +1,88 @@
+package flagly
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ScriptResult is the result of a line run by RunScript
+type ScriptResult struct {
+	// line number, starts from 1
+	Line int
+	// names of the handlers which ran, without the root
+	Path     []string
+	Err      error
+	Duration time.Duration
+}
+
+// ScriptError is the error of a line run by RunScript
+type ScriptError struct {
+	Line int
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, strings.TrimRight(e.Err.Error(), "\n"))
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// RunScript runs the commands in r one per line, the lines are parsed the
+// same as Interactive, including `use`, `..` and `help`. Empty lines and
+// the comments starting with `#` are skipped.
+//
+// It stops at the first failed line unless SetContinueOnError(true), the
+// returned error is a *ScriptError of the first failed line and the
+// results are of the lines which ran. No line runs if the root options
+// can't be applied, e.g. an invalid environment variable.
+func (f *FlaglySet) RunScript(r io.Reader) ([]ScriptResult, error) {
+	var (
+		results  []ScriptResult
+		firstErr error
+	)
+	scope, err := newShellScope(f.subHandler)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		st := f.subHandler.setting.newRun()
+		start := time.Now()
+		err := f.runLine(scope, st, line)
+		result := ScriptResult{
+			Line:     lineNo,
+			Err:      err,
+			Duration: time.Since(start),
+		}
+		if hs := st.effective.handlers; len(hs) > 0 {
+			// the handler which ran is the last one entered
+			result.Path = hs[len(hs)-1].subPath()
+		}
+		results = append(results, result)
+
+		if err != nil {
+			if firstErr == nil {
+				firstErr = &ScriptError{Line: lineNo, Err: err}
+			}
+			if !f.subHandler.setting.continueOnError {
+				return results, firstErr
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return results, err
+	}
+	return results, firstErr
+}
@@ -0,0 +1,131 @@
+package flagly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	fset, err := Compile("", &replRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.NewBuffer(nil)
+	fset.SetOutput(stdout)
+	script := `# replay
+echo hello 'big world' # trailing comment
+
+ehco
+echo -u after
+`
+	results, err := fset.RunScript(strings.NewReader(script))
+	var scriptErr *ScriptError
+	if !errors.As(err, &scriptErr) || scriptErr.Line != 4 {
+		t.Fatal("error", err)
+	}
+	if len(results) != 2 || results[0].Line != 2 ||
+		strings.Join(results[0].Path, " ") != "echo" || results[0].Err != nil {
+		t.Fatal("error", results)
+	}
+	if stdout.String() != "hello big world\n" {
+		t.Fatal("error", stdout.String())
+	}
+
+	stdout.Reset()
+	fset.SetContinueOnError(true)
+	results, err = fset.RunScript(strings.NewReader(script))
+	if !errors.As(err, &scriptErr) || scriptErr.Line != 4 {
+		t.Fatal("error", err)
+	}
+	if len(results) != 3 || results[1].Err == nil || results[2].Line != 5 {
+		t.Fatal("error", results)
+	}
+	if stdout.String() != "hello big world\nAFTER\n" {
+		t.Fatal("error", stdout.String())
+	}
+
+	fset, err = Compile("", &scopeRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.SetOutput(stdout)
+	results, err = fset.RunScript(strings.NewReader("use remote -name origin\nshow\n"))
+	if err != nil || len(results) != 2 ||
+		strings.Join(results[1].Path, " ") != "remote show" {
+		t.Fatal("error", err, results)
+	}
+}
+
+type scriptPersistRoot struct {
+	Verbose bool              `name:"v" persistent:"true"`
+	Sub     *scriptPersistSub `flagly:"handler"`
+}
+
+type scriptPersistSub struct {
+	Leaf *scriptPersistLeaf `flagly:"handler"`
+}
+
+type scriptPersistLeaf struct {
+	Root *scriptPersistRoot `flagly:"parent"`
+}
+
+var scriptPersistSeen []bool
+
+func (l *scriptPersistLeaf) FlaglyHandle() error {
+	scriptPersistSeen = append(scriptPersistSeen, l.Root.Verbose)
+	return nil
+}
+
+func TestRunScriptPersistent(t *testing.T) {
+	fset, err := Compile("", &scriptPersistRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptPersistSeen = nil
+	_, err = fset.RunScript(strings.NewReader(
+		"use sub\nleaf -v\nleaf\nuse .. sub -v\nleaf\nuse .. sub\nleaf\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the flags of a line don't leak, the ones given to `use` are kept in
+	// the level
+	if fmt.Sprint(scriptPersistSeen) != "[true false true false]" {
+		t.Fatal("error", scriptPersistSeen)
+	}
+}
+
+func TestRunScriptNoOptions(t *testing.T) {
+	fset := New("app")
+	grp := NewHandler("remote")
+	fset.Add(grp)
+	grp.AddSubHandler("add", func(*struct {
+		N int `name:"n"`
+	}) error {
+		return nil
+	})
+	results, err := fset.RunScript(strings.NewReader("remote add -n 1\n"))
+	if err != nil || len(results) != 1 ||
+		strings.Join(results[0].Path, " ") != "remote add" {
+		t.Fatal("error", err, results)
+	}
+}
+
+type scriptEnvRoot struct {
+	Port int               `name:"port" env:"SCRIPT_PORT"`
+	Sub  *scriptPersistSub `flagly:"handler"`
+}
+
+func TestRunScriptInvalidEnv(t *testing.T) {
+	fset, err := Compile("", &scriptEnvRoot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SCRIPT_PORT", "abc")
+	_, err = fset.RunScript(strings.NewReader("use sub\n"))
+	if err == nil || !strings.Contains(err.Error(), "$SCRIPT_PORT") {
+		t.Fatal("error", err)
+	}
+}
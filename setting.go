@@ -0,0 +1,131 @@
+package flagly
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// setting holds the parsing behaviors of a handler tree, it's shared by
+// all the handlers just like the context.
+type setting struct {
+	// split `-xvf` into `-x -v -f`
+	bundling bool
+	// ignore the flags which are not defined instead of failing
+	allowUnknown bool
+	// the max edit distance of the "did you mean" suggestions, 0 to disable
+	suggestDistance int
+	// derives the environment variables of the flags, see Option.EnvName
+	envPrefix string
+	// option values from the config file given by LoadConfig or SetConfig,
+	// overridden by env and flags
+	config *ConfigFile
+	// add `-config <path>` to the root handler
+	configFlag bool
+	// where the builtin commands write to
+	output io.Writer
+	// where the warnings of the deprecated options and commands write to
+	warnOutput io.Writer
+	// match the commands by a unique prefix of the names
+	prefixMatch bool
+	// parse the flags after the arguments of the leaf handlers
+	interspersed bool
+	// the typers used to compile the options
+	types *TypeRegistry
+	// RunScript runs the rest of the lines after a line fails
+	continueOnError bool
+}
+
+func newSetting() *setting {
+	return &setting{
+		suggestDistance: 2,
+		output:          os.Stdout,
+		warnOutput:      os.Stderr,
+		types:           NewTypeRegistry(defaultTypes),
+	}
+}
+
+func loadConfig(path string) (*ConfigFile, error) {
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// runState is the state of a single Run or Bind, it's not kept in the
+// setting so a handler tree can run in several goroutines.
+type runState struct {
+	// the option values resolved by the run
+	effective *Effective
+	// the config of the setting, `-config <path>` replaces it for the run
+	config *ConfigFile
+	// the persistent options which are not given to their handlers in the
+	// command line, the first one given to a sub handler replaces the value
+	// from default, env or config file. The required ones are checked after
+	// the leaf handler parsed.
+	unsetPersistent []unsetOption
+	// the required options which are not given, they are checked once the
+	// leaf handler parsed, so a sub command can still show its help.
+	missing []*Option
+}
+
+// unsetOption is a persistent option which is not given to its handler in
+// the command line
+type unsetOption struct {
+	op *Option
+	// the value is from env or the config file
+	provided bool
+}
+
+// setPersistent removes op from the unset ones, it reports whether op was
+// unset.
+func (st *runState) setPersistent(op *Option) bool {
+	for idx, unset := range st.unsetPersistent {
+		if unset.op == op {
+			st.unsetPersistent = append(st.unsetPersistent[:idx:idx], st.unsetPersistent[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequired returns the *MissingOptionsError of the required options
+// which are not given to the leaf handler h or its ancestors. Only the ones
+// of h are checked if h ignores the ancestors, e.g. `completion`.
+func (st *runState) checkRequired(h *Handler) error {
+	var missing []*Option
+	for _, op := range st.missing {
+		if op.handler == h || !h.ignoreRequired {
+			missing = append(missing, op)
+		}
+	}
+	for _, unset := range st.unsetPersistent {
+		if !unset.provided && !unset.op.IsOptional() && !h.ignoreRequired {
+			missing = append(missing, unset.op)
+		}
+	}
+	return missingError(h, missing)
+}
+
+// missingError returns the *MissingOptionsError of ops, nil if it's empty
+func missingError(h *Handler, ops []*Option) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	names := make([]string, len(ops))
+	for idx, op := range ops {
+		names[idx] = op.DisplayName()
+	}
+	return usageError(&MissingOptionsError{
+		Names: names,
+		Path:  h.Path(),
+	})
+}
+
+func (s *setting) newRun() *runState {
+	return &runState{
+		effective: new(Effective),
+		config:    s.config,
+	}
+}
@@ -0,0 +1,77 @@
+package flagly
+
+import "sort"
+
+// editDistance is the levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a int, others ...int) int {
+	for _, o := range others {
+		if o < a {
+			a = o
+		}
+	}
+	return a
+}
+
+// suggest returns the candidates which are at most maxDistance away from
+// name, the closest ones come first.
+func suggest(name string, candidates []string, maxDistance int) []string {
+	groups := make([][]string, len(candidates))
+	for idx, c := range candidates {
+		groups[idx] = []string{c}
+	}
+	return suggestFirst(name, groups, maxDistance)
+}
+
+// suggestFirst is like suggest but each of the candidates has several
+// names, e.g. a command and its aliases. The first name of a candidate is
+// returned once if any of its names is close, by the closest distance.
+func suggestFirst(name string, candidates [][]string, maxDistance int) []string {
+	if maxDistance <= 0 {
+		return nil
+	}
+	distances := make(map[string]int)
+	var ret []string
+next:
+	for _, names := range candidates {
+		if _, ok := distances[names[0]]; ok {
+			continue
+		}
+		d := maxDistance + 1
+		for _, n := range names {
+			if n == name {
+				continue next
+			}
+			d = minInt(d, editDistance(name, n))
+		}
+		if d > maxDistance {
+			continue
+		}
+		distances[names[0]] = d
+		ret = append(ret, names[0])
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return distances[ret[i]] < distances[ret[j]]
+	})
+	return ret
+}
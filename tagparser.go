@@ -31,31 +31,26 @@ func (st StructTag) GetPtr(name string) *string {
 }
 
 func (st StructTag) Get(name string) string {
-	var idx int
 	s := string(st)
-	target := name + ":"
-	for {
-		idx = strings.Index(s, target)
-		if idx >= 0 {
-			if strings.Count(s, `"`)&1 == 0 {
-				break
-			} else {
-				s = s[idx+1:]
-			}
-		} else {
-			return ""
-		}
+	idx := st.keyIndex(name, true)
+	if idx < 0 {
+		return ""
 	}
 
 	// found
 	quoted := false
 	content := bytes.NewBuffer(nil)
-	for i := idx + len(target); i < len(s); i++ {
+	for i := idx + len(name) + 1; i < len(s); i++ {
 		isQuoteChar := s[i] == '"'
 		if !quoted && s[i] == ' ' {
 			break
 		}
 		content.WriteByte(s[i])
+		if quoted && s[i] == '\\' && i+1 < len(s) {
+			i++
+			content.WriteByte(s[i])
+			continue
+		}
 		if isQuoteChar {
 			if !quoted {
 				quoted = true
@@ -73,27 +68,47 @@ func (st StructTag) Get(name string) string {
 	return ret
 }
 
+// Has reports whether the tag has the key name, either as a bare word or
+// as `name:"value"`.
 func (st StructTag) Has(name string) bool {
+	return st.keyIndex(name, false) >= 0
+}
+
+// keyIndex returns the index of the key name which starts a word, or -1.
+// The words inside the quoted values are skipped. If withValue, the key
+// must be followed by a colon.
+func (st StructTag) keyIndex(name string, withValue bool) int {
 	s := string(st)
-	var idx int
-	for {
-		idx = strings.Index(s, name)
-		if idx < 0 {
-			return false
-		}
-		if idx > 0 && s[idx-1] != ' ' {
-			continue
-		}
-		if len(s) > idx+len(name) {
-			ss := s[idx+len(name)]
-			if ss == ' ' || ss == ':' {
-				return true
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && (i == 0 || s[i-1] == ' ') && strings.HasPrefix(s[i:], name):
+			end := i + len(name)
+			if end < len(s) && s[end] == ':' ||
+				!withValue && (end == len(s) || s[end] == ' ') {
+				return i
 			}
-		} else {
-			return true
 		}
-		s = s[idx+1:]
 	}
+	return -1
+}
+
+// Bool reports whether the key name is set to true, the bare word like
+// `required` is the same as `required:"true"`.
+func (st StructTag) Bool(name string) bool {
+	if !st.Has(name) {
+		return false
+	}
+	val := st.Get(name)
+	if val == "" {
+		return true
+	}
+	ret, err := strconv.ParseBool(val)
+	return err == nil && ret
 }
 
 func (st StructTag) GetName() string {
@@ -35,6 +35,19 @@ func TestTagParser(t *testing.T) {
 		t.Fatal("error")
 	}
 
+	st = StructTag(`desc:"the account is required: here" required:"false"`)
+	if !st.Has("required") || st.Bool("required") || st.Get("required") != "false" {
+		t.Fatal("error")
+	}
+	st = StructTag(`desc:"show hidden files"`)
+	if st.Has("hidden") || st.Has("files") || st.Bool("hidden") {
+		t.Fatal("error")
+	}
+	st = StructTag(`hidden desc:"a \"secret\" one" secret:"true"`)
+	if !st.Bool("hidden") || !st.Bool("secret") || st.Get("desc") != `a "secret" one` {
+		t.Fatal("error")
+	}
+
 	st = StructTag(`"quote"`)
 	if st.GetName() != "quote" {
 		t.Fatal("error")
@@ -1,54 +1,118 @@
 package flagly
 
 import (
+	"encoding"
+	"errors"
+	"flag"
 	"fmt"
+	"math"
 	"net"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	types    = map[string]Typer{}
-	NilValue reflect.Value
+	// defaultTypes is the registry of the builtin typers and the ones
+	// registered by Register, the FlaglySets inherit from it.
+	defaultTypes = NewTypeRegistry(nil)
+	NilValue     reflect.Value
 )
 
 func init() {
-	RegisterAll(Bool{}, String{}, Duration{}, Int{}, Int64{}, IPNet{})
-	Register(MapStringString{})
+	RegisterAll(Bool{}, String{}, Duration{}, IPNet{},
+		Int{}, Int8{}, Int16{}, Int32{}, Int64{},
+		Uint{}, Uint8{}, Uint16{}, Uint32{}, Uint64{},
+		Float32{}, Float64{})
+	Register(MapStringString{}, Count(0))
 }
 
-func getTypeName(t reflect.Type) string {
+// typeKey is the key of t in TypeRegistry, *T and T share the same Typer
+func typeKey(t reflect.Type) reflect.Type {
 	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+		return t.Elem()
 	}
-	return t.String()
+	return t
 }
 
+// RegisterAll registers the value and slice typers of the parsers to the
+// default registry, which is shared by all the FlaglySets. Prefer
+// FlaglySet.TypeRegistry() to keep them local.
 func RegisterAll(objs ...BaseTyperParser) {
+	defaultTypes.RegisterAll(objs...)
+}
+
+// Register registers the typers to the default registry, see RegisterAll
+func Register(objs ...Typer) {
+	defaultTypes.Register(objs...)
+}
+
+// GetTyper returns the Typer of t from the default registry
+func GetTyper(t reflect.Type) (Typer, error) {
+	return defaultTypes.GetTyper(t)
+}
+
+// TypeRegistry maps the types to their Typers, the types not registered
+// are looked up in the parent. It's safe for concurrent use.
+type TypeRegistry struct {
+	parent *TypeRegistry
+	mutex  sync.RWMutex
+	types  map[reflect.Type]Typer
+}
+
+// NewTypeRegistry returns an empty registry which inherits from parent,
+// the parent is never modified through it.
+func NewTypeRegistry(parent *TypeRegistry) *TypeRegistry {
+	return &TypeRegistry{
+		parent: parent,
+		types:  make(map[reflect.Type]Typer),
+	}
+}
+
+// RegisterAll registers the value and slice typers of the parsers
+func (r *TypeRegistry) RegisterAll(objs ...BaseTyperParser) {
 	for _, obj := range objs {
-		Register(ValueWrap{obj}, SliceWrap{obj})
+		r.Register(ValueWrap{obj}, SliceWrap{obj})
 	}
 }
 
-func Register(objs ...Typer) {
+// Register registers the typers by their Type(), the existing ones are
+// replaced.
+func (r *TypeRegistry) Register(objs ...Typer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	for _, obj := range objs {
-		t := obj.Type()
-		if t.Kind() == reflect.Ptr {
-			t = t.Elem()
-		}
-		types[getTypeName(t)] = obj
+		r.types[typeKey(obj.Type())] = obj
 	}
 }
 
-func GetTyper(t reflect.Type) (Typer, error) {
-	name := getTypeName(t)
-	ret := types[name]
-	if ret != nil {
+func (r *TypeRegistry) lookup(t reflect.Type) Typer {
+	r.mutex.RLock()
+	ret := r.types[t]
+	r.mutex.RUnlock()
+	if ret == nil && r.parent != nil {
+		return r.parent.lookup(t)
+	}
+	return ret
+}
+
+// GetTyper returns the registered Typer of t, or an adapter if t or its
+// elements implement encoding.TextUnmarshaler or flag.Value.
+func (r *TypeRegistry) GetTyper(t reflect.Type) (Typer, error) {
+	if ret := r.lookup(typeKey(t)); ret != nil {
 		return ret, nil
 	}
-	return nil, fmt.Errorf("unknown type: %v", name)
+	if p := adaptTyper(t); p != nil {
+		return wrapTyper(p), nil
+	}
+	if t.Kind() == reflect.Slice {
+		if p := adaptTyper(t.Elem()); p != nil {
+			return SliceWrap{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown type: %v", typeKey(t))
 }
 
 type BaseTyper interface {
@@ -63,6 +127,12 @@ type BaseTypeCanBeValuer interface {
 type BaseTypeNumArgs interface {
 	NumArgs() (int, int)
 }
+
+// BaseTypeAccumulator makes every occurrence of a flag to be set, e.g. Count,
+// otherwise the last one overrides the others.
+type BaseTypeAccumulator interface {
+	Accumulate() bool
+}
 type BaseTyperParser interface {
 	BaseTyper
 	ParseArgs(args []string) (reflect.Value, error)
@@ -92,6 +162,9 @@ func (s SliceWrap) Set(source reflect.Value, args []string) error {
 	return nil
 }
 
+// Accumulate appends the values of every occurrence of the flag
+func (SliceWrap) Accumulate() bool { return true }
+
 func (s SliceWrap) CanBeValue(arg string) bool {
 	if cbv, ok := s.BaseTyperParser.(BaseTypeCanBeValuer); ok {
 		return cbv.CanBeValue(arg)
@@ -158,28 +231,243 @@ func (b ValueWrap) Set(source reflect.Value, args []string) error {
 	return nil
 }
 
+func elemType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// Count increases each time the flag is given, e.g. `-vvv` or `-v -v -v`
+// is 3, `-vvv` works without SetBundling. The `count` tag makes an integer
+// field work the same.
+type Count int
+
+func (Count) Type() reflect.Type  { return reflect.TypeOf(Count(0)) }
+func (Count) NumArgs() (int, int) { return 0, 0 }
+func (Count) Accumulate() bool    { return true }
+
+// CanBeValue accepts the numbers from the environment variables or the
+// config file, it never takes a value from the command line.
+func (Count) CanBeValue(arg string) bool {
+	_, err := strconv.ParseInt(arg, 10, 64)
+	return err == nil
+}
+
+// Set increases source by one, or sets it to the value from the default
+// tag, the environment variable or the config file.
+func (Count) Set(source reflect.Value, args []string) error {
+	if source.Kind() == reflect.Ptr {
+		if source.IsNil() {
+			source.Set(reflect.New(source.Type().Elem()))
+		}
+		source = source.Elem()
+	}
+	switch source.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := source.Int() + 1
+		if len(args) > 0 {
+			val, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			n = val
+		}
+		if source.OverflowInt(n) {
+			return fmt.Errorf("value out of range")
+		}
+		source.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := source.Uint() + 1
+		if len(args) > 0 {
+			val, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			n = val
+		}
+		if source.OverflowUint(n) {
+			return fmt.Errorf("value out of range")
+		}
+		source.SetUint(n)
+	default:
+		return fmt.Errorf("can't count on %v", source.Type())
+	}
+	return nil
+}
+
+// intBase strips the base prefix and the underscores of arg
+func intBase(arg string) (string, int) {
+	arg = strings.Replace(arg, "_", "", -1)
+	sign := ""
+	if strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+") {
+		sign, arg = arg[:1], arg[1:]
+	}
+	base := 10
+	if len(arg) >= 2 && arg[0] == '0' {
+		switch arg[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			arg = arg[2:]
+		}
+	}
+	return sign + arg, base
+}
+
+// parseInt parses arg into an integer of type t, it accepts the prefixes
+// like `0x`, `0o`, `0b` and the underscores like `1_000`,
+// a leading 0 is decimal unlike strconv with base 0.
+func parseInt(t reflect.Type, arg string) (reflect.Value, error) {
+	ret := reflect.New(t).Elem()
+	bits := uint(t.Bits())
+	arg, base := intBase(arg)
+	if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr {
+		val, err := strconv.ParseUint(strings.TrimPrefix(arg, "-"), base, int(bits))
+		if err == nil && val != 0 && strings.HasPrefix(arg, "-") {
+			// a negative number is out of range, not invalid
+			err = strconv.ErrRange
+		}
+		if err != nil {
+			return NilValue, numError(err, 0, ^uint64(0)>>(64-bits))
+		}
+		ret.SetUint(val)
+		return ret, nil
+	}
+	val, err := strconv.ParseInt(arg, base, int(bits))
+	if err != nil {
+		return NilValue, numError(err, int64(math.MinInt64)>>(64-bits), int64(math.MaxInt64)>>(64-bits))
+	}
+	ret.SetInt(val)
+	return ret, nil
+}
+
+func parseFloat(t reflect.Type, arg string) (reflect.Value, error) {
+	max := math.MaxFloat64
+	if t.Kind() == reflect.Float32 {
+		max = math.MaxFloat32
+	}
+	val, err := strconv.ParseFloat(strings.Replace(arg, "_", "", -1), t.Bits())
+	if err != nil {
+		return NilValue, numError(err, -max, max)
+	}
+	ret := reflect.New(t).Elem()
+	ret.SetFloat(val)
+	return ret, nil
+}
+
+// numError turns the error of strconv into "out of range [min, max]" or
+// "invalid syntax"
+func numError(err error, min, max interface{}) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("out of range [%v, %v]", min, max)
+	}
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+	return err
+}
+
 type Int struct{}
 
 func (Int) Type() reflect.Type { return reflect.TypeOf(int(0)) }
 func (Int) ArgName() string    { return "number" }
-func (Int) ParseArgs(args []string) (reflect.Value, error) {
-	val, err := strconv.Atoi(args[0])
-	if err != nil {
-		return NilValue, err
-	}
-	return reflect.ValueOf(val), nil
+func (t Int) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Int8 struct{}
+
+func (Int8) Type() reflect.Type { return reflect.TypeOf(int8(0)) }
+func (Int8) ArgName() string    { return "number" }
+func (t Int8) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Int16 struct{}
+
+func (Int16) Type() reflect.Type { return reflect.TypeOf(int16(0)) }
+func (Int16) ArgName() string    { return "number" }
+func (t Int16) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Int32 struct{}
+
+func (Int32) Type() reflect.Type { return reflect.TypeOf(int32(0)) }
+func (Int32) ArgName() string    { return "number" }
+func (t Int32) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
 }
 
 type Int64 struct{}
 
 func (Int64) Type() reflect.Type { return reflect.TypeOf(int64(0)) }
 func (Int64) ArgName() string    { return "number" }
-func (Int64) ParseArgs(args []string) (reflect.Value, error) {
-	val, err := strconv.ParseInt(args[0], 10, 64)
-	if err != nil {
-		return NilValue, err
-	}
-	return reflect.ValueOf(val), nil
+func (t Int64) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Uint struct{}
+
+func (Uint) Type() reflect.Type { return reflect.TypeOf(uint(0)) }
+func (Uint) ArgName() string    { return "number" }
+func (t Uint) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Uint8 struct{}
+
+func (Uint8) Type() reflect.Type { return reflect.TypeOf(uint8(0)) }
+func (Uint8) ArgName() string    { return "number" }
+func (t Uint8) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Uint16 struct{}
+
+func (Uint16) Type() reflect.Type { return reflect.TypeOf(uint16(0)) }
+func (Uint16) ArgName() string    { return "number" }
+func (t Uint16) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Uint32 struct{}
+
+func (Uint32) Type() reflect.Type { return reflect.TypeOf(uint32(0)) }
+func (Uint32) ArgName() string    { return "number" }
+func (t Uint32) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Uint64 struct{}
+
+func (Uint64) Type() reflect.Type { return reflect.TypeOf(uint64(0)) }
+func (Uint64) ArgName() string    { return "number" }
+func (t Uint64) ParseArgs(args []string) (reflect.Value, error) {
+	return parseInt(t.Type(), args[0])
+}
+
+type Float32 struct{}
+
+func (Float32) Type() reflect.Type { return reflect.TypeOf(float32(0)) }
+func (Float32) ArgName() string    { return "number" }
+func (t Float32) ParseArgs(args []string) (reflect.Value, error) {
+	return parseFloat(t.Type(), args[0])
+}
+
+type Float64 struct{}
+
+func (Float64) Type() reflect.Type { return reflect.TypeOf(float64(0)) }
+func (Float64) ArgName() string    { return "number" }
+func (t Float64) ParseArgs(args []string) (reflect.Value, error) {
+	return parseFloat(t.Type(), args[0])
 }
 
 type String struct{}
@@ -243,23 +531,161 @@ func (MapStringString) Type() reflect.Type {
 }
 func (MapStringString) NumArgs() (int, int)    { return 1, 1 }
 func (MapStringString) CanBeValue(string) bool { return true }
+func (MapStringString) Accumulate() bool       { return true }
 func (MapStringString) Set(source reflect.Value, args []string) error {
-	idx := strings.Index(args[0], "=")
-	if idx < 0 {
-		return fmt.Errorf("invalid config: %v", args[0])
-	}
 	elem := source
 	if source.Kind() == reflect.Ptr {
 		elem = source.Elem()
 	}
-	if elem.IsNil() {
-		elem.Set(reflect.ValueOf(map[string]string{}))
+	for _, arg := range args {
+		idx := strings.Index(arg, "=")
+		if idx < 0 {
+			return fmt.Errorf("invalid config: %v", arg)
+		}
+		if elem.IsNil() {
+			elem.Set(reflect.ValueOf(map[string]string{}))
+		}
+		key := arg[:idx]
+		value := arg[idx+1:]
+		elem.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
 	}
-	key := args[0][:idx]
-	value := args[0][idx+1:]
-	elem.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
 	return nil
 }
 func (MapStringString) ArgName() string {
 	return "key=value"
 }
+
+var (
+	textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+	flagValueType       = reflect.TypeOf(new(flag.Value)).Elem()
+)
+
+// adaptTyper returns the parser of t if t or *t implements
+// encoding.TextUnmarshaler or flag.Value (including flag.Getter).
+func adaptTyper(t reflect.Type) BaseTyperParser {
+	switch {
+	case implements(t, flagValueType):
+		return FlagValue{t}
+	case implements(t, textUnmarshalerType):
+		return TextUnmarshaler{t}
+	}
+	return nil
+}
+
+// implements reports whether t or *t implements iface
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// wrapTyper makes a Typer for the single value of p
+func wrapTyper(p BaseTyperParser) Typer {
+	if typer, ok := p.(Typer); ok {
+		return typer
+	}
+	return ValueWrap{p}
+}
+
+// newValue returns an addressable zero value of t, the pointers are
+// allocated.
+func newValue(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	if t.Kind() == reflect.Ptr {
+		v.Set(reflect.New(t.Elem()))
+	}
+	return v
+}
+
+// valueIface returns the interface implemented by v or its address
+func valueIface(v reflect.Value, iface reflect.Type) interface{} {
+	if v.Type().Implements(iface) {
+		return v.Interface()
+	}
+	return v.Addr().Interface()
+}
+
+// stringOf returns String() of v, it's false if v doesn't implement
+// fmt.Stringer or is a nil pointer.
+func stringOf(v reflect.Value) (string, bool) {
+	if !v.CanInterface() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", false
+	}
+	stringer, ok := valueIface(v, reflect.TypeOf(new(fmt.Stringer)).Elem()).(fmt.Stringer)
+	if !ok {
+		return "", false
+	}
+	return stringer.String(), true
+}
+
+// TextUnmarshaler adapts a type implementing encoding.TextUnmarshaler
+type TextUnmarshaler struct {
+	Typ reflect.Type
+}
+
+func (t TextUnmarshaler) Type() reflect.Type     { return t.Typ }
+func (t TextUnmarshaler) CanBeValue(string) bool { return true }
+func (t TextUnmarshaler) NumArgs() (int, int)    { return 1, 1 }
+func (t TextUnmarshaler) ParseArgs(args []string) (reflect.Value, error) {
+	v := newValue(t.Typ)
+	u := valueIface(v, textUnmarshalerType).(encoding.TextUnmarshaler)
+	if err := u.UnmarshalText([]byte(args[0])); err != nil {
+		return NilValue, err
+	}
+	return v, nil
+}
+
+// FlagValue adapts a type implementing flag.Value, Set is called on the
+// field itself for every occurrence like in the flag package.
+// It takes no value if it has `IsBoolFlag() bool` returning true.
+type FlagValue struct {
+	Typ reflect.Type
+}
+
+func (t FlagValue) Type() reflect.Type { return t.Typ }
+func (t FlagValue) Accumulate() bool   { return true }
+
+// CanBeValue only takes true or false after a bool flag like Bool
+func (t FlagValue) CanBeValue(arg string) bool {
+	if t.isBoolFlag() {
+		return arg == "true" || arg == "false"
+	}
+	return true
+}
+
+func (t FlagValue) NumArgs() (int, int) {
+	if t.isBoolFlag() {
+		return 0, 1
+	}
+	return 1, 1
+}
+
+func (t FlagValue) isBoolFlag() bool {
+	b, ok := valueIface(newValue(t.Typ), flagValueType).(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+func (t FlagValue) ParseArgs(args []string) (reflect.Value, error) {
+	v := newValue(t.Typ)
+	if err := t.Set(v, args); err != nil {
+		return NilValue, err
+	}
+	return v, nil
+}
+
+func (t FlagValue) Set(source reflect.Value, args []string) error {
+	if source.Kind() == reflect.Ptr && source.IsNil() {
+		source.Set(reflect.New(source.Type().Elem()))
+	}
+	value := valueIface(source, flagValueType).(flag.Value)
+	if len(args) == 0 {
+		// a bool flag
+		return value.Set("true")
+	}
+	for _, arg := range args {
+		if err := value.Set(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package flagly
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestTyper(t *testing.T) {
@@ -21,3 +26,432 @@ func TestTyper(t *testing.T) {
 		t.Fatal("error")
 	}
 }
+
+type countOpt struct {
+	Verbose Count    `name:"v"`
+	Level   int      `name:"l" count:"true"`
+	Small   uint8    `name:"s" count:"true" default:"255"`
+	Name    string   `name:"n"`
+	Limit   int      `name:"limit" desc:"the count limit"`
+	Off     int      `name:"off" count:"false"`
+	Label   string   `name:"label" desc:"count of what"`
+	Args    []string `type:"[]"`
+}
+
+func TestCount(t *testing.T) {
+	fset, err := Compile("", &countOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset.SetBundling(true)
+	var opt countOpt
+	e, err := fset.BindEffective(reflect.ValueOf(&opt), []string{"-vvv", "-l", "-n", "a", "-v", "-l", "-n", "b", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Verbose != 4 || opt.Level != 2 || opt.Small != 255 || opt.Name != "b" ||
+		len(opt.Args) != 1 || opt.Args[0] != "x" {
+		t.Fatal("error", opt)
+	}
+	if line := e.FlagLine(); line != "-v -v -v -v -l -l -n=b x" {
+		t.Fatal("error", line)
+	}
+
+	// a count from env is replayed as the flags
+	fset.SetEnvPrefix("CNT")
+	t.Setenv("CNT_L", "2")
+	e, err = fset.BindEffective(reflect.ValueOf(&opt), []string{"x"})
+	if err != nil || opt.Level != 2 {
+		t.Fatal("error", err, opt)
+	}
+	line := e.FlagLine()
+	if line != "-l -l x" {
+		t.Fatal("error", line)
+	}
+	if err := fset.Bind(reflect.ValueOf(&opt), strings.Fields(line)); err != nil || opt.Level != 2 {
+		t.Fatal("error", err, opt)
+	}
+	fset.SetEnvPrefix("")
+
+	fset.SetBundling(false)
+	opt = countOpt{}
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-vvv", "-ll", "-v"}); err != nil ||
+		opt.Verbose != 4 || opt.Level != 2 {
+		t.Fatal("error", err, opt)
+	}
+	var unknown *UnknownFlagError
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-vl"}); !errors.As(err, &unknown) {
+		t.Fatal("error", err)
+	}
+	fset.SetBundling(true)
+
+	// the default is not the start
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-s"}); err != nil || opt.Small != 1 {
+		t.Fatal("error", err, opt)
+	}
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-v=2"}); err == nil {
+		t.Fatal("error", opt)
+	}
+
+	// the word in desc and count:"false" are not counters
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-limit", "5", "-off", "6", "-label", "x"}); err != nil ||
+		opt.Limit != 5 || opt.Off != 6 || opt.Label != "x" {
+		t.Fatal("error", err, opt)
+	}
+
+	type badCount struct {
+		Name string `count:"true"`
+	}
+	if _, err := Compile("", &badCount{}); err == nil {
+		t.Fatal("error")
+	}
+}
+
+type accumulateOpt struct {
+	Tags   []string          `name:"tag"`
+	Ports  []int             `name:"p" sep:","`
+	Labels map[string]string `name:"label"`
+	Hosts  []string          `name:"host" sep:"," default:"a,b"`
+}
+
+func TestAccumulate(t *testing.T) {
+	fset, err := Compile("", &accumulateOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt accumulateOpt
+	err = fset.Bind(reflect.ValueOf(&opt), []string{
+		"-tag", "a", "-label", "k1=v1", "-tag=b,c",
+		"-p", "1,2", "-p", "3", "-label", "k2=v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opt.Tags, []string{"a", "b,c"}) ||
+		!reflect.DeepEqual(opt.Ports, []int{1, 2, 3}) ||
+		!reflect.DeepEqual(opt.Labels, map[string]string{"k1": "v1", "k2": "v2"}) ||
+		!reflect.DeepEqual(opt.Hosts, []string{"a", "b"}) {
+		t.Fatal("error", opt)
+	}
+
+	if err := fset.Bind(reflect.ValueOf(&opt), []string{"-p", "1,x"}); err == nil {
+		t.Fatal("error", opt)
+	}
+
+	// a scalar can't take several values
+	_, err = Compile("", &struct {
+		Label string `name:"label" sep:","`
+	}{})
+	if err == nil || !strings.Contains(err.Error(), "sep of label") {
+		t.Fatal("error", err)
+	}
+}
+
+type numericOpt struct {
+	Port   uint16    `name:"port"`
+	Small  int8      `name:"small"`
+	Big    uint64    `name:"big"`
+	Mask   uint32    `name:"mask"`
+	Rate   float32   `name:"rate"`
+	Ratios []float64 `name:"ratio"`
+	Ids    []int32   `name:"id"`
+	Count  int       `name:"n"`
+}
+
+func TestNumeric(t *testing.T) {
+	fset, err := Compile("", &numericOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt numericOpt
+	err = fset.Bind(reflect.ValueOf(&opt), []string{
+		"-port", "8080", "-small", "-128", "-big", "18446744073709551615",
+		"-mask", "0xff_ff", "-rate", "1.5", "-ratio", "0.1", "-ratio", "1_000.5",
+		"-id", "0b101", "-id", "0o17", "-id", "010", "-id", "08", "-id", "-0x10",
+		"-n", "1_000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Port != 8080 || opt.Small != -128 || opt.Big != 1<<64-1 ||
+		opt.Mask != 0xffff || opt.Rate != 1.5 || opt.Count != 1000 ||
+		!reflect.DeepEqual(opt.Ratios, []float64{0.1, 1000.5}) ||
+		!reflect.DeepEqual(opt.Ids, []int32{5, 15, 10, 8, -16}) {
+		t.Fatal("error", opt)
+	}
+
+	for args, msg := range map[string]string{
+		"-port=65536": `invalid value "65536" for -port: out of range [0, 65535]`,
+		"-small=128":  `invalid value "128" for -small: out of range [-128, 127]`,
+		"-port=-1":    `invalid value "-1" for -port: out of range [0, 65535]`,
+		"-port=-0x1":  `invalid value "-0x1" for -port: out of range [0, 65535]`,
+		"-port=-a":    `invalid value "-a" for -port: invalid syntax`,
+		"-rate=1e39":  `invalid value "1e39" for -rate: out of range`,
+		"-n=abc":      `invalid value "abc" for -n: invalid syntax`,
+		"-n=0x":       `invalid value "0x" for -n: invalid syntax`,
+	} {
+		err := fset.Bind(reflect.ValueOf(&opt), []string{args})
+		if err == nil || !strings.HasPrefix(err.Error(), msg) {
+			t.Fatal("error", args, err)
+		}
+	}
+}
+
+type logLevel int
+
+func (l logLevel) String() string {
+	return [...]string{"info", "debug"}[l]
+}
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*l = 0
+	case "debug":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level")
+	}
+	return nil
+}
+
+type listValue struct {
+	items []string
+}
+
+func (l *listValue) String() string {
+	return strings.Join(l.items, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	l.items = append(l.items, s)
+	return nil
+}
+
+type boolValue bool
+
+func (b *boolValue) String() string     { return fmt.Sprint(bool(*b)) }
+func (b *boolValue) Set(s string) error { *b = s == "true"; return nil }
+func (b *boolValue) IsBoolFlag() bool   { return true }
+
+type ifaceOpt struct {
+	Level  logLevel   `name:"level" arg:"level"`
+	Levels []logLevel `name:"levels"`
+	Ptr    *logLevel  `name:"ptr"`
+	Since  time.Time  `name:"since"`
+	IPs    []net.IP   `name:"ip"`
+	List   listValue  `name:"list"`
+	Lists  *listValue `name:"lists"`
+	Force  boolValue  `name:"f"`
+}
+
+func TestIfaceTyper(t *testing.T) {
+	fset, err := Compile("", &ifaceOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt ifaceOpt
+	err = fset.Bind(reflect.ValueOf(&opt), []string{
+		"-level", "debug", "-levels", "debug", "-levels", "info", "-ptr", "debug",
+		"-since", "2020-01-02T03:04:05Z", "-ip", "127.0.0.1", "-ip", "::1",
+		"-list", "a", "-list", "b", "-lists", "c", "-f",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Level != 1 || !reflect.DeepEqual(opt.Levels, []logLevel{1, 0}) ||
+		opt.Ptr == nil || *opt.Ptr != 1 || opt.Since.Year() != 2020 ||
+		len(opt.IPs) != 2 || !opt.IPs[1].Equal(net.ParseIP("::1")) ||
+		opt.List.String() != "a,b" || opt.Lists.String() != "c" || !bool(opt.Force) {
+		t.Fatal("error", opt)
+	}
+
+	err = fset.Bind(reflect.ValueOf(&opt), []string{"-level", "warn"})
+	if err == nil || !strings.Contains(err.Error(), "unknown level") {
+		t.Fatal("error", err)
+	}
+
+	// a bool flag doesn't take the positional as its value
+	type boolArgOpt struct {
+		Force boolValue `name:"f"`
+		Files []string  `type:"[]"`
+	}
+	fset2, err := Compile("", &boolArgOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt2 boolArgOpt
+	if err := fset2.Bind(reflect.ValueOf(&opt2), []string{"-f", "file.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if !bool(opt2.Force) || !reflect.DeepEqual(opt2.Files, []string{"file.txt"}) {
+		t.Fatal("error", opt2)
+	}
+	opt2 = boolArgOpt{}
+	if err := fset2.Bind(reflect.ValueOf(&opt2), []string{"-f", "false", "file.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if bool(opt2.Force) || !reflect.DeepEqual(opt2.Files, []string{"file.txt"}) {
+		t.Fatal("error", opt2)
+	}
+
+	// the zero values are not defaults
+	usage := fset.Usage()
+	if !strings.Contains(usage, "-level <level>\n") ||
+		!strings.Contains(usage, "-ptr\n") ||
+		!strings.Contains(usage, "-since\n") ||
+		strings.Contains(usage, "0001-01-01") || strings.Contains(usage, "<nil>") {
+		t.Fatal("error", usage)
+	}
+}
+
+type ifaceInitOpt struct {
+	Level logLevel  `name:"level"`
+	Since time.Time `name:"since"`
+	IP    net.IP    `name:"ip"`
+	List  listValue `name:"list"`
+}
+
+func (o *ifaceInitOpt) FlaglyInit() {
+	o.Level = 1
+	o.List.items = []string{"a", "b"}
+}
+
+func TestIfaceTyperDefault(t *testing.T) {
+	fset, err := Compile("", &ifaceInitOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the values set by FlaglyInit are shown, the zero values are not
+	usage := fset.Usage()
+	if !strings.Contains(usage, `-level "debug"`) ||
+		!strings.Contains(usage, `-list "a,b"`) ||
+		!strings.Contains(usage, "-since\n") ||
+		strings.Contains(usage, "0001-01-01") || strings.Contains(usage, "<nil>") {
+		t.Fatal("error", usage)
+	}
+}
+
+type registryColor struct {
+	rgb string
+}
+
+type registryColorTyper struct {
+	prefix string
+}
+
+func (registryColorTyper) Type() reflect.Type { return reflect.TypeOf(registryColor{}) }
+func (t registryColorTyper) ParseArgs(args []string) (reflect.Value, error) {
+	return reflect.ValueOf(registryColor{t.prefix + args[0]}), nil
+}
+
+type registryOpt struct {
+	Color registryColor `name:"color"`
+	Sub   *registrySub  `flagly:"handler"`
+}
+
+type registrySub struct {
+	Colors []registryColor `name:"c"`
+}
+
+type registryLevel string
+
+func (l *registryLevel) UnmarshalText(text []byte) error {
+	*l = registryLevel(text)
+	return nil
+}
+
+type registryLevelTyper struct{}
+
+func (registryLevelTyper) Type() reflect.Type { return reflect.TypeOf(registryLevel("")) }
+func (registryLevelTyper) ParseArgs(args []string) (reflect.Value, error) {
+	return reflect.ValueOf(registryLevel("3:" + args[0])), nil
+}
+
+type registryAdded struct {
+	Level registryLevel `name:"l"`
+}
+
+func TestTypeRegistry(t *testing.T) {
+	if _, err := Compile("", &registryOpt{}); err == nil {
+		t.Fatal("error")
+	}
+
+	set1, set2 := New(""), New("")
+	set1.TypeRegistry().RegisterAll(registryColorTyper{"1:"})
+	set2.TypeRegistry().RegisterAll(registryColorTyper{"2:"})
+	for idx, fset := range []*FlaglySet{set1, set2} {
+		if err := fset.Compile(&registryOpt{}); err != nil {
+			t.Fatal(err)
+		}
+		var opt registryOpt
+		if err := fset.Bind(reflect.ValueOf(&opt), []string{"-color", "red"}); err != nil {
+			t.Fatal(err)
+		}
+		if opt.Color.rgb != fmt.Sprintf("%v:red", idx+1) {
+			t.Fatal("error", opt)
+		}
+	}
+	if _, err := GetTyper(reflect.TypeOf(registryColor{})); err == nil {
+		t.Fatal("the default registry is changed")
+	}
+
+	// the handlers added later use the typers of the set
+	set3 := New("")
+	set3.TypeRegistry().RegisterAll(registryColorTyper{"3:"})
+	var colors []registryColor
+	set3.AddSubHandler("sub", func(opt *registrySub) error {
+		colors = opt.Colors
+		return nil
+	})
+	if err := set3.Run([]string{"sub", "-c", "red"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(colors, []registryColor{{"3:red"}}) {
+		t.Fatal("error", colors)
+	}
+
+	// compiled with the default typers before added
+	set3.TypeRegistry().RegisterAll(registryLevelTyper{})
+	var level registryLevel
+	h := NewHandler("added")
+	h.SetHandleFunc(func(opt *registryAdded) error {
+		level = opt.Level
+		return nil
+	})
+	// the changes to the options are kept
+	h.Options[0].Desc = "custom desc"
+	set3.Add(h)
+	if err := set3.Run([]string{"added", "-l", "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if level != "3:debug" {
+		t.Fatal("error", level)
+	}
+	if usage := h.Usage(""); !strings.Contains(usage, "custom desc") {
+		t.Fatal("error", usage)
+	}
+
+	// same name, different type
+	type registryColor struct{}
+	r := NewTypeRegistry(defaultTypes)
+	r.RegisterAll(registryColorTyper{})
+	if reflect.TypeOf(registryColor{}).String() != (registryColorTyper{}).Type().String() {
+		t.Fatal("error")
+	}
+	if typer, err := r.GetTyper(reflect.TypeOf(registryColor{})); err == nil {
+		t.Fatal("error", typer)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			r.RegisterAll(registryColorTyper{})
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		r.GetTyper(reflect.TypeOf([]registryColor{}))
+	}
+	<-done
+}
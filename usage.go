@@ -11,9 +11,14 @@ var (
 
 type showUsageError struct {
 	info     string
+	err      error
 	handlers []*Handler
 }
 
+func (s showUsageError) Unwrap() error {
+	return s.err
+}
+
 func (s showUsageError) Error() string {
 	if s.info != "" {
 		usage := s.Usage()
@@ -45,6 +50,73 @@ func Error(info string) error {
 	}
 }
 
+// usageError shows the usage after the error message of err,
+// err still can be retrieved by errors.As.
+func usageError(err error) error {
+	return &showUsageError{
+		info: err.Error(),
+		err:  err,
+	}
+}
+
+type UnknownFlagError struct {
+	Name string
+	// the path of the handler which received the flag
+	Path        []string
+	Suggestions []string
+}
+
+func (e *UnknownFlagError) Error() string {
+	return unknownMessage("flag", e.Name, e.Path, e.Suggestions)
+}
+
+type UnknownCommandError struct {
+	Name        string
+	Path        []string
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return unknownMessage("command", e.Name, e.Path, e.Suggestions)
+}
+
+// AmbiguousCommandError is returned if a prefix matches more than one
+// command, see FlaglySet.SetPrefixMatching.
+type AmbiguousCommandError struct {
+	Name       string
+	Path       []string
+	Candidates []string
+}
+
+func (e *AmbiguousCommandError) Error() string {
+	msg := fmt.Sprintf("ambiguous command '%v'", e.Name)
+	if len(e.Path) > 0 {
+		msg += fmt.Sprintf(" for '%v'", strings.Join(e.Path, " "))
+	}
+	return msg + fmt.Sprintf(", could be '%v'", strings.Join(e.Candidates, "', '"))
+}
+
+// MissingOptionsError lists all the required options which are not given
+type MissingOptionsError struct {
+	Names []string
+	Path  []string
+}
+
+func (e *MissingOptionsError) Error() string {
+	return fmt.Sprintf("missing required options: %v", strings.Join(e.Names, ", "))
+}
+
+func unknownMessage(kind, name string, path, suggestions []string) string {
+	msg := fmt.Sprintf("unknown %v '%v'", kind, name)
+	if len(path) > 0 {
+		msg += fmt.Sprintf(" for '%v'", strings.Join(path, " "))
+	}
+	if len(suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean '%v'?", strings.Join(suggestions, "', '"))
+	}
+	return msg
+}
+
 func ShowUsage(hs []*Handler) string {
 	prefix := ""
 	for i := len(hs) - 1; i > 0; i-- {
@@ -55,8 +127,9 @@ func ShowUsage(hs []*Handler) string {
 		h := hs[0]
 		usage := h.Usage(prefix)
 		for i := 1; i < len(hs); i++ {
-			if hs[i].HasFlagOptions() {
-				usage += hs[i].usageOptions(hs[i].Name + " options")
+			// the persistent ones are in the global options of h
+			if ops := hs[i].localFlagOptions(); len(ops) > 0 {
+				usage += usageOptionList(hs[i].Name+" options", ops)
 			}
 		}
 		return usage